@@ -8,6 +8,7 @@ import (
 	"simplerpc/codec"
 	"simplerpc/discovery"
 	"sync"
+	"time"
 )
 
 type Call struct {
@@ -44,7 +45,20 @@ type Client struct {
 	shutdown bool
 }
 
+var ErrShutdown = errors.New("rpc client: connection is shut down")
+
+// Caller 可以发起调用的客户端，Client和XClient都实现了该接口，生成的类型化客户端基于它
+type Caller interface {
+	Call(serviceMethod string, args, rly interface{}) error
+}
+
 func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrShutdown
+	}
+	c.closed = true
 	return c.cc.Close()
 }
 
@@ -53,11 +67,17 @@ func (c *Client) isUnavailable() bool {
 	return c.closed || c.shutdown
 }
 
+func (c *Client) available() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.isUnavailable()
+}
+
 func (c *Client) registryCall(call *Call) (uint64, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.isUnavailable() {
-		return 0, errors.New("rpc client: error shutdown")
+		return 0, ErrShutdown
 	}
 	call.seq = c.seq
 	c.seq++
@@ -81,6 +101,7 @@ func (c *Client) terminalAllCalls() {
 
 	c.shutdown = true
 	for _, call := range c.pending {
+		call.err = ErrShutdown
 		call.done()
 	}
 }
@@ -94,11 +115,42 @@ func Dial(network string, d discovery.Discovery, options ...*codec.Option) (*Cli
 	if disErr != nil {
 		return nil, disErr
 	}
-	conn, dialErr := net.Dial(network, rpcAddr)
+	return dial(network, rpcAddr, option)
+}
+
+// DialAddr 跳过服务发现，直接连接指定地址的服务器
+func DialAddr(network, addr string, options ...*codec.Option) (*Client, error) {
+	option, err := parseOption(options...)
+	if err != nil {
+		return nil, err
+	}
+	return dial(network, addr, option)
+}
+
+// DialTimeout 与DialAddr相同，建立连接超过timeout时返回错误
+func DialTimeout(network, addr string, timeout time.Duration, options ...*codec.Option) (*Client, error) {
+	option, err := parseOption(options...)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout(network, addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return newClientConn(conn, option)
+}
+
+func dial(network, addr string, option *codec.Option) (*Client, error) {
+	conn, dialErr := net.Dial(network, addr)
 	if dialErr != nil {
 		return nil, dialErr
 	}
+	return newClientConn(conn, option)
+}
+
+func newClientConn(conn net.Conn, option *codec.Option) (*Client, error) {
 	if err := json.NewEncoder(conn).Encode(option); err != nil {
+		_ = conn.Close()
 		return nil, err
 	}
 	f := codec.CodecFuncTable[option.CodecType]
@@ -118,6 +170,31 @@ func (c *Client) Call(serviceMethod string, args interface{}, rly interface{}) e
 	return call.err
 
 }
+
+// Notify 单向调用，请求写出后立即返回，不等待也不占用pending，服务器执行后不返回响应，
+// 因此方法的执行结果和错误都拿不到，适合事件通知、日志等不需要确认的场景
+func (c *Client) Notify(serviceMethod string, args interface{}) error {
+	c.sending.Lock()
+	defer c.sending.Unlock()
+	c.mu.Lock()
+	if c.isUnavailable() {
+		c.mu.Unlock()
+		return ErrShutdown
+	}
+	seq := c.seq
+	c.seq++
+	c.mu.Unlock()
+
+	c.header.ServiceMethod = serviceMethod
+	c.header.Seq = seq
+	c.header.Err = ""
+	c.header.OneWay = true
+	if c.cc.WriteHeader(c.header) != nil || c.cc.WriteBody(args) != nil {
+		return fmt.Errorf("rpc client:notify_%d failed to write request", seq)
+	}
+	return nil
+}
+
 func newClient(cc codec.Codec) *Client {
 
 	client := &Client{
@@ -144,15 +221,18 @@ func (c *Client) receive() {
 		//1 为nil：发送请求未完整或者被取消了，但服务器处理了
 		//2 不为nil，但是Err不为nil：处理出错了
 		//3 正常处理
+		//服务器对出错的请求同样会写回一个空的请求体，需要读掉
 		switch {
 		case call == nil:
-			err = errors.New("rpc client: handled not existed call")
+			err = c.cc.ReadBody(nil)
 		case h.Err != "":
 			call.err = errors.New(h.Err)
-			err = call.err
+			err = c.cc.ReadBody(nil)
 			call.done()
 		default:
-			err = c.cc.ReadBody(call.rly)
+			if err = c.cc.ReadBody(call.rly); err != nil {
+				call.err = errors.New("rpc client: reading body " + err.Error())
+			}
 			call.done()
 		}
 
@@ -183,6 +263,7 @@ func (c *Client) send(call *Call) {
 	c.header.ServiceMethod = call.serviceMethod
 	c.header.Seq = seq
 	c.header.Err = ""
+	c.header.OneWay = false
 	if c.cc.WriteHeader(c.header) != nil || c.cc.WriteBody(call.args) != nil {
 		c.removeCall(seq)
 		call.err = fmt.Errorf("rpc client:call_%d failed to write request", call.seq)
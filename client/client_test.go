@@ -0,0 +1,87 @@
+package client
+
+import (
+	"errors"
+	"simplerpc/codec"
+	"simplerpc/server"
+	"testing"
+	"time"
+)
+
+func (s *Sleeper) Fail(msg string, rly *int) error {
+	return errors.New(msg)
+}
+
+func TestCallError(t *testing.T) {
+	addr := startSleeper(t)
+	c, err := DialAddr("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	//出错的请求不影响同一连接上的后续请求
+	var rly int
+	for _, method := range []string{"Sleeper.Fail", "Unknown.Method", "Sleeper.Unknown", "Sleeper"} {
+		if err := c.Call(method, "boom", &rly); err == nil {
+			t.Fatalf("expect %s to fail", method)
+		}
+		if err := c.Call("Sleeper.Sleep", 1, &rly); err != nil || rly != 1 {
+			t.Fatalf("expect connection usable after %s error, got %v", method, err)
+		}
+	}
+	if err := c.Call("Sleeper.Fail", "boom", &rly); err == nil || err.Error() != "boom" {
+		t.Fatalf("expect handler error returned to caller, got %v", err)
+	}
+}
+
+// Sink 记录收到的单向调用
+type Sink struct {
+	events chan string
+}
+
+func (s *Sink) Record(event string, rly *struct{}) error {
+	s.events <- event
+	return nil
+}
+
+var sink = &Sink{events: make(chan string, 10)}
+
+func TestNotify(t *testing.T) {
+	addr := startSleeper(t)
+	_ = server.Registry(sink)
+	for _, codecType := range []codec.Type{codec.GobType, codec.JsonType} {
+		c, err := DialAddr("tcp", addr, &codec.Option{MagicNumber: codec.MagicNum, CodecType: codecType})
+		if err != nil {
+			t.Fatal(err)
+		}
+		//出错的单向调用同样没有响应，不会干扰后续调用
+		for _, method := range []string{"Sink.Record", "Sleeper.Fail", "Unknown.Method"} {
+			if err := c.Notify(method, "event"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		c.mu.Lock()
+		pending := len(c.pending)
+		c.mu.Unlock()
+		if pending != 0 {
+			t.Fatalf("expect one-way calls not to be pending, got %d", pending)
+		}
+		select {
+		case event := <-sink.events:
+			if event != "event" {
+				t.Fatalf("unexpected event %q", event)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s: one-way call not received", codecType)
+		}
+		var rly int
+		if err := c.Call("Sleeper.Sleep", 1, &rly); err != nil || rly != 1 {
+			t.Fatalf("%s: expect connection usable after one-way calls, got %v", codecType, err)
+		}
+		_ = c.Close()
+		if err := c.Notify("Sink.Record", "event"); err != ErrShutdown {
+			t.Fatalf("expect ErrShutdown after close, got %v", err)
+		}
+	}
+}
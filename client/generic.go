@@ -0,0 +1,66 @@
+package client
+
+import (
+	"fmt"
+	"reflect"
+	"simplerpc/schema"
+	"strings"
+)
+
+// Invoke 类型安全的调用，Resp可以是指针类型（如*service.Foo）或值类型（如int）
+func Invoke[Req, Resp any](c Caller, serviceMethod string, req Req) (Resp, error) {
+	var resp Resp
+	if t := reflect.TypeOf(&resp).Elem(); t.Kind() == reflect.Ptr {
+		resp = reflect.New(t.Elem()).Interface().(Resp)
+		err := c.Call(serviceMethod, req, resp)
+		return resp, err
+	}
+	err := c.Call(serviceMethod, req, &resp)
+	return resp, err
+}
+
+// Method 类型化的方法句柄，创建时通过服务器的反射服务检查方法存在且参数和返回值的类型一致，之后可以反复调用
+type Method[Req, Resp any] struct {
+	c             Caller
+	serviceMethod string
+}
+
+// NewMethod 创建serviceMethod的句柄，方法不存在或类型与服务器不一致时返回错误
+func NewMethod[Req, Resp any](c Caller, serviceMethod string) (*Method[Req, Resp], error) {
+	parts := strings.Split(serviceMethod, ".")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("rpc client: invalid service method %q", serviceMethod)
+	}
+	reply := new(schema.ListResponse)
+	if err := c.Call(schema.ListMethod, &schema.ListRequest{Service: parts[0]}, reply); err != nil {
+		return nil, fmt.Errorf("rpc client: describe %s: %v", serviceMethod, err)
+	}
+	var method *schema.MethodInfo
+	for _, svc := range reply.Services {
+		for _, m := range svc.Methods {
+			if svc.Name == parts[0] && m.Name == parts[1] {
+				method = m
+			}
+		}
+	}
+	if method == nil {
+		return nil, fmt.Errorf("rpc client: method %s not found", serviceMethod)
+	}
+	var req Req
+	var resp Resp
+	if err := schema.Describe(reflect.TypeOf(&req).Elem()).Compatible(method.Arg); err != nil {
+		return nil, fmt.Errorf("rpc client: %s argument: %v", serviceMethod, err)
+	}
+	if err := schema.Describe(reflect.TypeOf(&resp).Elem()).Compatible(method.Reply); err != nil {
+		return nil, fmt.Errorf("rpc client: %s reply: %v", serviceMethod, err)
+	}
+	return &Method[Req, Resp]{c: c, serviceMethod: serviceMethod}, nil
+}
+
+func (m *Method[Req, Resp]) Invoke(req Req) (Resp, error) {
+	return Invoke[Req, Resp](m.c, m.serviceMethod, req)
+}
+
+func (m *Method[Req, Resp]) Name() string {
+	return m.serviceMethod
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"simplerpc/server"
+	"testing"
+)
+
+type Pair struct {
+	A, B int
+}
+
+type Calc int
+
+func (c *Calc) Add(p *Pair, rly *int) error {
+	*rly = p.A + p.B
+	return nil
+}
+
+func (c *Calc) Swap(p Pair, rly *Pair) error {
+	*rly = Pair{A: p.B, B: p.A}
+	return nil
+}
+
+func TestInvoke(t *testing.T) {
+	addr := startSleeper(t)
+	_ = server.Registry(new(Calc))
+	c, err := DialAddr("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if sum, err := Invoke[*Pair, int](c, "Calc.Add", &Pair{A: 1, B: 2}); err != nil || sum != 3 {
+		t.Fatalf("expect 3, got %d %v", sum, err)
+	}
+	if p, err := Invoke[Pair, *Pair](c, "Calc.Swap", Pair{A: 1, B: 2}); err != nil || p.A != 2 || p.B != 1 {
+		t.Fatalf("expect swapped pair, got %v %v", p, err)
+	}
+
+	add, err := NewMethod[Pair, int64](c, "Calc.Add")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if sum, err := add.Invoke(Pair{A: i, B: i}); err != nil || sum != int64(2*i) {
+			t.Fatalf("expect %d, got %d %v", 2*i, sum, err)
+		}
+	}
+
+	//创建句柄时检查方法是否存在以及类型是否一致
+	if _, err := NewMethod[Pair, int](c, "Calc.Sub"); err == nil {
+		t.Fatal("expect unknown method rejected")
+	}
+	if _, err := NewMethod[int, int](c, "Calc.Add"); err == nil {
+		t.Fatal("expect mismatched argument rejected")
+	}
+	if _, err := NewMethod[*Pair, string](c, "Calc.Add"); err == nil {
+		t.Fatal("expect mismatched reply rejected")
+	}
+	if _, err := NewMethod[struct{ A int }, int](c, "Calc.Add"); err == nil {
+		t.Fatal("expect missing field rejected")
+	}
+}
@@ -0,0 +1,276 @@
+package client
+
+import (
+	"errors"
+	"simplerpc/codec"
+	"sync"
+	"time"
+)
+
+type PoolOption struct {
+	MaxConns    int           //每个地址最多维护的连接数
+	MaxIdle     time.Duration //连接空闲超过该时长后关闭，0表示不限制
+	MaxLifetime time.Duration //连接存活超过该时长后不再分配新的调用，在途调用结束后关闭，0表示不限制
+}
+
+var DefaultPoolOption = &PoolOption{
+	MaxConns: 4,
+	MaxIdle:  90 * time.Second,
+}
+
+var ErrPoolClosed = errors.New("rpc pool: pool is closed")
+
+type pooledClient struct {
+	*Client
+	created  time.Time
+	lastUsed time.Time
+	inflight int //由pool分配出去、尚未结束的调用数
+}
+
+func (pc *pooledClient) expired(popt *PoolOption, now time.Time) bool {
+	return popt.MaxLifetime > 0 && pc.created.Add(popt.MaxLifetime).Before(now)
+}
+
+func (pc *pooledClient) idle(popt *PoolOption, now time.Time) bool {
+	return popt.MaxIdle > 0 && pc.inflight == 0 && pc.lastUsed.Add(popt.MaxIdle).Before(now)
+}
+
+// Pool 为每个服务器地址维护多条连接
+// 调用优先分配给在途调用最少的连接，所有连接都繁忙且未达到上限时才新建连接，
+// 这样大包调用不会阻塞同一条TCP流上的小包调用
+type Pool struct {
+	network string
+	option  *codec.Option
+	popt    *PoolOption
+	dial    func(network, addr string, option *codec.Option) (*Client, error)
+
+	mu      sync.Mutex
+	cond    *sync.Cond //连接建立完成时唤醒等待名额的调用
+	conns   map[string][]*pooledClient
+	dialing map[string]int  //正在建立的连接数，计入MaxConns
+	retired []*pooledClient //超过MaxLifetime等待在途调用结束后关闭的连接
+	closed  bool
+	stop    chan struct{}
+}
+
+func NewPool(network string, popt *PoolOption, options ...*codec.Option) (*Pool, error) {
+	option, err := parseOption(options...)
+	if err != nil {
+		return nil, err
+	}
+	if popt == nil {
+		popt = DefaultPoolOption
+	}
+	if popt.MaxConns <= 0 {
+		return nil, errors.New("rpc pool: MaxConns must be positive")
+	}
+	p := &Pool{
+		network: network,
+		option:  option,
+		popt:    popt,
+		dial:    dial,
+		conns:   make(map[string][]*pooledClient),
+		dialing: make(map[string]int),
+		stop:    make(chan struct{}),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	if interval := p.cleanInterval(); interval > 0 {
+		go p.cleaner(interval)
+	}
+	return p, nil
+}
+
+// Call 在addr对应的连接池中选一条连接发起调用
+func (p *Pool) Call(addr, serviceMethod string, args, rly interface{}) error {
+	pc, err := p.acquire(addr)
+	if err != nil {
+		return err
+	}
+	defer p.release(pc)
+	return pc.Call(serviceMethod, args, rly)
+}
+
+// Notify 在addr对应的连接池中选一条连接发起单向调用
+func (p *Pool) Notify(addr, serviceMethod string, args interface{}) error {
+	pc, err := p.acquire(addr)
+	if err != nil {
+		return err
+	}
+	defer p.release(pc)
+	return pc.Notify(serviceMethod, args)
+}
+
+// acquire 建立连接时不持有p.mu，某个地址连接缓慢不会阻塞到其他地址的调用；
+// 正在建立的连接预先占用名额，所有名额都在建立连接时等待其完成
+func (p *Pool) acquire(addr string) (*pooledClient, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var best *pooledClient
+	for {
+		if p.closed {
+			return nil, ErrPoolClosed
+		}
+		p.prune(addr, time.Now())
+		best = nil
+		for _, pc := range p.conns[addr] {
+			if best == nil || pc.inflight < best.inflight {
+				best = pc
+			}
+		}
+		full := len(p.conns[addr])+p.dialing[addr] >= p.popt.MaxConns
+		if best != nil && (best.inflight == 0 || full) {
+			return p.use(best), nil
+		}
+		if !full {
+			break
+		}
+		p.cond.Wait()
+	}
+
+	p.dialing[addr]++
+	p.mu.Unlock()
+	c, err := p.dial(p.network, addr, p.option)
+	p.mu.Lock()
+	if p.dialing[addr]--; p.dialing[addr] == 0 {
+		delete(p.dialing, addr)
+	}
+	p.cond.Broadcast()
+	if p.closed {
+		if err == nil {
+			_ = c.Close()
+		}
+		return nil, ErrPoolClosed
+	}
+	if err != nil {
+		//已有连接时退回到在途调用最少的连接
+		if best == nil || !best.available() {
+			return nil, err
+		}
+		return p.use(best), nil
+	}
+	pc := &pooledClient{Client: c, created: time.Now()}
+	p.conns[addr] = append(p.conns[addr], pc)
+	return p.use(pc), nil
+}
+
+// use 在持有p.mu时调用
+func (p *Pool) use(pc *pooledClient) *pooledClient {
+	pc.inflight++
+	pc.lastUsed = time.Now()
+	return pc
+}
+
+func (p *Pool) release(pc *pooledClient) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pc.inflight--
+	pc.lastUsed = time.Now()
+}
+
+// prune 移除addr下已断开的连接，并将超过MaxLifetime的连接转入retired
+func (p *Pool) prune(addr string, now time.Time) {
+	conns := p.conns[addr][:0]
+	for _, pc := range p.conns[addr] {
+		switch {
+		case !pc.available():
+			_ = pc.Close()
+		case pc.expired(p.popt, now):
+			p.retired = append(p.retired, pc)
+		default:
+			conns = append(conns, pc)
+		}
+	}
+	if len(conns) == 0 {
+		delete(p.conns, addr)
+		return
+	}
+	p.conns[addr] = conns
+}
+
+func (p *Pool) cleanInterval() time.Duration {
+	interval := p.popt.MaxIdle
+	if p.popt.MaxLifetime > 0 && (interval == 0 || p.popt.MaxLifetime < interval) {
+		interval = p.popt.MaxLifetime
+	}
+	if interval > time.Second {
+		interval = time.Second
+	}
+	return interval
+}
+
+func (p *Pool) cleaner(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-t.C:
+			p.clean()
+		}
+	}
+}
+
+// clean 关闭空闲超时的连接，以及已无在途调用的retired连接
+func (p *Pool) clean() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for addr := range p.conns {
+		p.prune(addr, now)
+		conns := p.conns[addr][:0]
+		for _, pc := range p.conns[addr] {
+			if pc.idle(p.popt, now) {
+				_ = pc.Close()
+				continue
+			}
+			conns = append(conns, pc)
+		}
+		if len(conns) == 0 {
+			delete(p.conns, addr)
+			continue
+		}
+		p.conns[addr] = conns
+	}
+	retired := p.retired[:0]
+	for _, pc := range p.retired {
+		if pc.inflight == 0 {
+			_ = pc.Close()
+			continue
+		}
+		retired = append(retired, pc)
+	}
+	p.retired = retired
+}
+
+// Remove 关闭并移除到addr的所有连接，在服务器下线时调用
+func (p *Pool) Remove(addr string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, pc := range p.conns[addr] {
+		_ = pc.Close()
+	}
+	delete(p.conns, addr)
+}
+
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrPoolClosed
+	}
+	p.closed = true
+	close(p.stop)
+	p.cond.Broadcast()
+	for addr, conns := range p.conns {
+		for _, pc := range conns {
+			_ = pc.Close()
+		}
+		delete(p.conns, addr)
+	}
+	for _, pc := range p.retired {
+		_ = pc.Close()
+	}
+	p.retired = nil
+	return nil
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"simplerpc/codec"
+	"simplerpc/server"
+	"sync"
+	"testing"
+	"time"
+)
+
+type Sleeper int
+
+func (s *Sleeper) Sleep(ms int, rly *int) error {
+	time.Sleep(time.Duration(ms) * time.Millisecond)
+	*rly = ms
+	return nil
+}
+
+func startSleeper(t *testing.T) string {
+	_ = server.Registry(new(Sleeper))
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept(lis)
+	return lis.Addr().String()
+}
+
+func (p *Pool) connCount(addr string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.conns[addr])
+}
+
+func TestPool(t *testing.T) {
+	addr := startSleeper(t)
+	p, err := NewPool("tcp", &PoolOption{MaxConns: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var rly int
+			if err := p.Call(addr, "Sleeper.Sleep", 100, &rly); err != nil || rly != 100 {
+				t.Errorf("call failed: %v, %d", err, rly)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := p.connCount(addr); n < 2 || n > 3 {
+		t.Fatalf("expect calls spread over 2..3 conns, got %d", n)
+	}
+
+	//断开的连接在下一次调用时被替换
+	p.mu.Lock()
+	for _, pc := range p.conns[addr] {
+		_ = pc.Client.Close()
+	}
+	p.mu.Unlock()
+	var rly int
+	if err := p.Call(addr, "Sleeper.Sleep", 1, &rly); err != nil {
+		t.Fatal(err)
+	}
+	if n := p.connCount(addr); n != 1 {
+		t.Fatalf("expect broken conns replaced by 1 conn, got %d", n)
+	}
+}
+
+func TestPoolMaxIdleAndLifetime(t *testing.T) {
+	addr := startSleeper(t)
+	p, err := NewPool("tcp", &PoolOption{MaxConns: 2, MaxIdle: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	var rly int
+	if err := p.Call(addr, "Sleeper.Sleep", 1, &rly); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if n := p.connCount(addr); n != 0 {
+		t.Fatalf("expect idle conn closed, got %d", n)
+	}
+
+	lp, err := NewPool("tcp", &PoolOption{MaxConns: 1, MaxLifetime: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lp.Close()
+	first, err := lp.acquire(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp.release(first)
+	time.Sleep(100 * time.Millisecond)
+	second, err := lp.acquire(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp.release(second)
+	if first == second || first.available() {
+		t.Fatal("expect expired conn retired and closed")
+	}
+}
+
+func TestPoolSlowDial(t *testing.T) {
+	addr := startSleeper(t)
+	p, err := NewPool("tcp", &PoolOption{MaxConns: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	//到slow的连接一直建立不上
+	release := make(chan struct{})
+	p.dial = func(network, target string, option *codec.Option) (*Client, error) {
+		if target == "slow" {
+			<-release
+			return nil, errors.New("dial slow: timeout")
+		}
+		return dial(network, target, option)
+	}
+	slowDone := make(chan error, 1)
+	go func() {
+		slowDone <- p.Call("slow", "Sleeper.Sleep", 1, new(int))
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Call(addr, "Sleeper.Sleep", 1, new(int))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("call blocked by a slow dial to another address")
+	}
+	close(release)
+	if err := <-slowDone; err == nil {
+		t.Fatal("expect dial error of slow address")
+	}
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"simplerpc/codec"
+	"simplerpc/discovery"
+)
+
+// SelectMode 负载均衡客户端选择服务器的方式
+type SelectMode int
+
+const (
+	RoundRobinSelect         SelectMode = iota //依次轮询discovery中的服务器
+	ConsistentHashSelect                       //按调用方提供的key在一致性哈希环上选择服务器
+	WeightedRoundRobinSelect                   //按注册中心登记的实例权重平滑加权轮询
+)
+
+// Keyer 一致性哈希模式下，参数实现该接口时用其返回值作为路由的key（如用户ID）
+type Keyer interface {
+	HashKey() string
+}
+
+// XClient 负载均衡客户端
+// 通过discovery拿到服务器列表，按SelectMode选出一台服务器，并通过连接池复用到每个地址的连接
+type XClient struct {
+	d    discovery.Discovery
+	mode SelectMode
+	ring *discovery.HashRing
+	wrr  *discovery.WeightedRoundRobin
+	pool *Pool
+}
+
+func NewXClient(network string, d discovery.Discovery, mode SelectMode, options ...*codec.Option) (*XClient, error) {
+	return NewXClientWithPool(network, d, mode, DefaultPoolOption, options...)
+}
+
+func NewXClientWithPool(network string, d discovery.Discovery, mode SelectMode, popt *PoolOption, options ...*codec.Option) (*XClient, error) {
+	if _, ok := d.(discovery.InstanceDiscovery); !ok && mode == WeightedRoundRobinSelect {
+		return nil, errors.New("rpc xclient: weighted round robin select requires a discovery implementing InstanceDiscovery")
+	}
+	pool, err := NewPool(network, popt, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &XClient{
+		d:    d,
+		mode: mode,
+		ring: discovery.NewHashRing(0, nil),
+		wrr:  discovery.NewWeightedRoundRobin(),
+		pool: pool,
+	}, nil
+}
+
+// Call 按照XClient的SelectMode选择服务器
+// 一致性哈希模式下args需要实现Keyer，否则请使用CallKey
+func (xc *XClient) Call(serviceMethod string, args, rly interface{}) error {
+	if xc.mode == ConsistentHashSelect {
+		keyer, ok := args.(Keyer)
+		if !ok {
+			return errors.New("rpc xclient: consistent hash select requires args implementing Keyer or CallKey")
+		}
+		return xc.CallKey(keyer.HashKey(), serviceMethod, args, rly)
+	}
+	return xc.call(xc.selectServer, func(addr string) error {
+		return xc.pool.Call(addr, serviceMethod, args, rly)
+	})
+}
+
+// Notify 按照XClient的SelectMode选择服务器发起单向调用，一致性哈希模式下args需要实现Keyer
+func (xc *XClient) Notify(serviceMethod string, args interface{}) error {
+	selectServer := xc.selectServer
+	if xc.mode == ConsistentHashSelect {
+		keyer, ok := args.(Keyer)
+		if !ok {
+			return errors.New("rpc xclient: consistent hash select requires args implementing Keyer")
+		}
+		selectServer = xc.keySelector(keyer.HashKey())
+	}
+	return xc.call(selectServer, func(addr string) error {
+		return xc.pool.Notify(addr, serviceMethod, args)
+	})
+}
+
+// CallKey 将key映射到一致性哈希环上，相同的key总是落到同一台服务器
+func (xc *XClient) CallKey(key, serviceMethod string, args, rly interface{}) error {
+	return xc.call(xc.keySelector(key), func(addr string) error {
+		return xc.pool.Call(addr, serviceMethod, args, rly)
+	})
+}
+
+func (xc *XClient) keySelector(key string) func() (string, error) {
+	return func() (string, error) {
+		servers, err := xc.d.GetAll()
+		if err != nil {
+			return "", err
+		}
+		xc.ring.Set(servers)
+		return xc.ring.Get(key)
+	}
+}
+
+func (xc *XClient) selectServer() (string, error) {
+	if xc.mode == WeightedRoundRobinSelect {
+		instances, err := xc.d.(discovery.InstanceDiscovery).GetInstances()
+		if err != nil {
+			return "", err
+		}
+		ins, err := xc.wrr.Next(instances)
+		if err != nil {
+			return "", err
+		}
+		return ins.Addr, nil
+	}
+	return xc.d.Get()
+}
+
+// call 连接失败说明服务器已下线，立即将其从服务列表中剔除，
+// 由于请求还没有发出，可以安全地重新选择一台服务器再试一次
+func (xc *XClient) call(selectServer func() (string, error), do func(addr string) error) error {
+	var err error
+	for i := 0; i < 2; i++ {
+		var addr string
+		if addr, err = selectServer(); err != nil {
+			return err
+		}
+		err = do(addr)
+		if !isDialError(err) {
+			return err
+		}
+		if remover, ok := xc.d.(discovery.Remover); ok {
+			remover.Remove(addr)
+		}
+		xc.pool.Remove(addr)
+	}
+	return err
+}
+
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func (xc *XClient) Close() error {
+	return xc.pool.Close()
+}
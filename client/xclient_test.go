@@ -0,0 +1,158 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"simplerpc/discovery"
+	"simplerpc/server"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// staticDiscovery 服务器列表固定的discovery
+type staticDiscovery struct {
+	mu        sync.Mutex
+	instances []*discovery.Instance
+	index     int
+}
+
+func (d *staticDiscovery) Refresh() error { return nil }
+
+func (d *staticDiscovery) Update(servers []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.instances = d.instances[:0]
+	for _, addr := range servers {
+		d.instances = append(d.instances, &discovery.Instance{Addr: addr, Weight: 1})
+	}
+}
+
+func (d *staticDiscovery) Get() (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.instances) == 0 {
+		return "", errors.New("no available servers")
+	}
+	d.index++
+	return d.instances[d.index%len(d.instances)].Addr, nil
+}
+
+func (d *staticDiscovery) GetAll() ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	servers := make([]string, 0, len(d.instances))
+	for _, ins := range d.instances {
+		servers = append(servers, ins.Addr)
+	}
+	return servers, nil
+}
+
+func (d *staticDiscovery) GetInstances() ([]*discovery.Instance, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*discovery.Instance(nil), d.instances...), nil
+}
+
+// Shard 返回处理请求的服务器地址
+type Shard struct {
+	addr string
+}
+
+type ShardKey struct {
+	User string
+}
+
+func (k ShardKey) HashKey() string {
+	return k.User
+}
+
+func (s *Shard) Whoami(key ShardKey, rly *string) error {
+	*rly = s.addr
+	return nil
+}
+
+// startShards 启动n个注册了Shard服务的服务器，返回它们的地址
+func startShards(t *testing.T, n int) []string {
+	addrs := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := server.NewServer()
+		_ = s.Registry(&Shard{addr: lis.Addr().String()})
+		go s.Accept(lis)
+		t.Cleanup(s.Shutdown)
+		addrs = append(addrs, lis.Addr().String())
+	}
+	return addrs
+}
+
+func TestXClientConsistentHash(t *testing.T) {
+	addrs := startShards(t, 3)
+	d := new(staticDiscovery)
+	d.Update(addrs)
+	xc, err := NewXClient("tcp", d, ConsistentHashSelect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer xc.Close()
+
+	var rly string
+	if err := xc.Call("Shard.Whoami", "no key", &rly); err == nil {
+		t.Fatal("expect args without Keyer to be refused")
+	}
+	//同一个key总是落到同一台服务器，CallKey与Keyer的结果一致，服务器顺序不影响路由
+	reversed := &staticDiscovery{}
+	reversed.Update([]string{addrs[2], addrs[1], addrs[0]})
+	other, err := NewXClient("tcp", reversed, ConsistentHashSelect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	used := make(map[string]bool)
+	for i := 0; i < 30; i++ {
+		key := ShardKey{User: "user-" + strconv.Itoa(i)}
+		var first, second, byKey, fromOther string
+		if err := xc.Call("Shard.Whoami", key, &first); err != nil {
+			t.Fatal(err)
+		}
+		_ = xc.Call("Shard.Whoami", key, &second)
+		_ = xc.CallKey(key.User, "Shard.Whoami", key, &byKey)
+		_ = other.Call("Shard.Whoami", key, &fromOther)
+		if first != second || first != byKey || first != fromOther {
+			t.Fatalf("key %s is not sticky: %s %s %s %s", key.User, first, second, byKey, fromOther)
+		}
+		used[first] = true
+	}
+	if len(used) < 2 {
+		t.Fatalf("expect keys spread over servers, got %v", used)
+	}
+}
+
+func TestXClientWeightedRoundRobin(t *testing.T) {
+	addrs := startShards(t, 2)
+	d := &staticDiscovery{instances: []*discovery.Instance{{Addr: addrs[0], Weight: 3}, {Addr: addrs[1], Weight: 1}}}
+	//不提供实例元数据的discovery不能用于加权轮询
+	if _, err := NewXClient("tcp", struct{ discovery.Discovery }{d}, WeightedRoundRobinSelect); err == nil {
+		t.Fatal("expect discovery without GetInstances to be refused")
+	}
+	xc, err := NewXClient("tcp", d, WeightedRoundRobinSelect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer xc.Close()
+
+	count := make(map[string]int)
+	for i := 0; i < 8; i++ {
+		var rly string
+		if err := xc.Call("Shard.Whoami", ShardKey{}, &rly); err != nil {
+			t.Fatal(err)
+		}
+		count[rly]++
+	}
+	if count[addrs[0]] != 6 || count[addrs[1]] != 2 {
+		t.Fatalf("expect calls split 6:2 by weight, got %v", count)
+	}
+}
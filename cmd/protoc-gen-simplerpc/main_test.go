@@ -0,0 +1,121 @@
+package main
+
+import (
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func greeterFile(streaming bool) *descriptorpb.FileDescriptorProto {
+	message := func(name string) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{
+			Name: proto.String(name),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("name"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				JsonName: proto.String("name"),
+			}},
+		}
+	}
+	return &descriptorpb.FileDescriptorProto{
+		Name:        proto.String("greeter.proto"),
+		Package:     proto.String("greeter"),
+		Syntax:      proto.String("proto3"),
+		Options:     &descriptorpb.FileOptions{GoPackage: proto.String("simplerpc/greeter")},
+		MessageType: []*descriptorpb.DescriptorProto{message("HelloRequest"), message("HelloReply")},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Greeter"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:            proto.String("SayHello"),
+				InputType:       proto.String(".greeter.HelloRequest"),
+				OutputType:      proto.String(".greeter.HelloReply"),
+				ServerStreaming: proto.Bool(streaming),
+			}},
+		}},
+	}
+}
+
+func runPlugin(t *testing.T, file *descriptorpb.FileDescriptorProto) *pluginpb.CodeGeneratorResponse {
+	gen, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(gen); err != nil {
+		gen.Error(err)
+	}
+	return gen.Response()
+}
+
+func TestGenerate(t *testing.T) {
+	resp := runPlugin(t, greeterFile(false))
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	if len(resp.File) != 1 || resp.File[0].GetName() != "simplerpc/greeter/greeter_simplerpc.pb.go" {
+		t.Fatalf("unexpected files: %v", resp.File)
+	}
+	content := resp.File[0].GetContent()
+	for _, expect := range []string{
+		"type GreeterServer interface {\n\tSayHello(args *HelloRequest, reply *HelloReply) error\n}",
+		"type Greeter struct {",
+		"func RegisterGreeterServer(s *server.Server, impl GreeterServer) error {",
+		"func NewGreeterClient(c client.Caller) *GreeterClient {",
+		"func (c *GreeterClient) SayHello(args *HelloRequest) (*HelloReply, error) {",
+		`c.c.Call("Greeter.SayHello", args, reply)`,
+		`server "simplerpc/server"`,
+	} {
+		if !strings.Contains(content, expect) {
+			t.Fatalf("expect generated code to contain %q:\n%s", expect, content)
+		}
+	}
+	if resp.GetSupportedFeatures()&uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL) == 0 {
+		t.Fatal("expect proto3 optional declared as supported")
+	}
+	checkGenerated(t, content)
+}
+
+// checkGenerated 确认生成的代码已经格式化，并且和protoc-gen-go生成的消息类型放在一起时能通过类型检查
+func checkGenerated(t *testing.T, content string) {
+	formatted, err := format.Source([]byte(content))
+	if err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, content)
+	}
+	if string(formatted) != content {
+		t.Fatalf("generated code is not gofmt-ed:\n%s", content)
+	}
+	fset := token.NewFileSet()
+	generated, err := parser.ParseFile(fset, "greeter_simplerpc.pb.go", content, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := parser.ParseFile(fset, "greeter.pb.go", "package greeter\n\ntype HelloRequest struct{ Name string }\ntype HelloReply struct{ Name string }\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("simplerpc/greeter", fset, []*ast.File{generated, messages}, nil); err != nil {
+		t.Fatalf("generated code does not type-check: %v\n%s", err, content)
+	}
+}
+
+func TestGenerateStreaming(t *testing.T) {
+	resp := runPlugin(t, greeterFile(true))
+	if !strings.Contains(resp.GetError(), "streaming method Greeter.SayHello") {
+		t.Fatalf("expect streaming method rejected, got %q", resp.GetError())
+	}
+}
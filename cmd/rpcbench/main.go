@@ -0,0 +1,270 @@
+// rpcbench simplerpc的压测工具，比较不同编码方式、负载大小和连接数下的吞吐量与延迟
+//
+//	rpcbench                                        在进程内启动服务器并压测
+//	rpcbench -serve 127.0.0.1:9999                  只启动提供Bench服务的服务器，供其他机器压测
+//	rpcbench -addr 127.0.0.1:9999 -codecs gob,proto -sizes 64,4096 -conns 1,8
+//	rpcbench -registry http://127.0.0.1:9998/_rpc_/registry -duration 30s
+//
+// 每组参数下由-concurrency个goroutine持续调用Bench.Echo，负载为service.Foo
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"simplerpc/client"
+	"simplerpc/codec"
+	"simplerpc/discovery"
+	"simplerpc/grpc/demo/service"
+	"simplerpc/server"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Bench 压测使用的服务，原样返回负载
+type Bench int
+
+func (b *Bench) Echo(args *service.Foo, rly *service.Foo) error {
+	rly.Name = args.Name
+	return nil
+}
+
+type options struct {
+	serve       string
+	addr        string
+	registry    string
+	codecs      []string
+	sizes       []int
+	conns       []int
+	concurrency int
+	duration    time.Duration
+}
+
+// result 一组参数的压测结果
+type result struct {
+	codec    string
+	size     int
+	conns    int
+	requests int
+	errors   int
+	elapsed  time.Duration
+	latency  []time.Duration //已排序
+}
+
+func main() {
+	log.SetOutput(io.Discard)
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "rpcbench:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	opt, err := parseFlags(args)
+	if err != nil {
+		return err
+	}
+	if opt.serve != "" {
+		lis, err := net.Listen("tcp", opt.serve)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, "rpcbench: serving Bench on", lis.Addr())
+		serve(lis)
+		return nil
+	}
+	if opt.addr == "" && opt.registry == "" {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return err
+		}
+		defer lis.Close()
+		go serve(lis)
+		opt.addr = lis.Addr().String()
+	}
+
+	results := make([]*result, 0)
+	for _, c := range opt.codecs {
+		for _, size := range opt.sizes {
+			for _, conns := range opt.conns {
+				res, err := bench(opt, c, size, conns)
+				if err != nil {
+					return fmt.Errorf("codec %s size %d conns %d: %v", c, size, conns, err)
+				}
+				results = append(results, res)
+			}
+		}
+	}
+	report(stdout, results)
+	return nil
+}
+
+func parseFlags(args []string) (*options, error) {
+	opt := new(options)
+	var codecs, sizes, conns string
+	fs := flag.NewFlagSet("rpcbench", flag.ContinueOnError)
+	fs.StringVar(&opt.serve, "serve", "", "only run a Bench server on this address")
+	fs.StringVar(&opt.addr, "addr", "", "server address; an in-process server is started when both -addr and -registry are empty")
+	fs.StringVar(&opt.registry, "registry", "", "registry url, calls are spread over discovered servers")
+	fs.StringVar(&codecs, "codecs", "gob,proto,json", "comma separated codecs: gob, proto, json")
+	fs.StringVar(&sizes, "sizes", "16,1024,16384", "comma separated payload sizes in bytes")
+	fs.StringVar(&conns, "conns", "1,4", "comma separated connection counts per server")
+	fs.IntVar(&opt.concurrency, "concurrency", 32, "concurrent callers")
+	fs.DurationVar(&opt.duration, "duration", 5*time.Second, "duration of each run")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	for _, name := range strings.Split(codecs, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if _, ok := codec.CodecFuncTable[codecType(name)]; !ok {
+			return nil, fmt.Errorf("unknown codec %q", name)
+		}
+		opt.codecs = append(opt.codecs, name)
+	}
+	var err error
+	if opt.sizes, err = parseInts(sizes); err != nil {
+		return nil, err
+	}
+	if opt.conns, err = parseInts(conns); err != nil {
+		return nil, err
+	}
+	if len(opt.codecs) == 0 || len(opt.sizes) == 0 || len(opt.conns) == 0 || opt.concurrency <= 0 {
+		return nil, errors.New("codecs, sizes, conns and concurrency must not be empty")
+	}
+	return opt, nil
+}
+
+func parseInts(s string) ([]int, error) {
+	ints := make([]int, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		n, err := strconv.Atoi(item)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid number %q", item)
+		}
+		ints = append(ints, n)
+	}
+	return ints, nil
+}
+
+func codecType(name string) codec.Type {
+	return "application/" + name
+}
+
+func serve(lis net.Listener) {
+	s := server.NewServer()
+	_ = s.Registry(new(Bench))
+	s.Accept(lis)
+}
+
+// caller 直连时使用连接池，通过注册中心时使用带连接池的XClient
+type caller interface {
+	Call(serviceMethod string, args, rly interface{}) error
+	Close() error
+}
+
+type poolCaller struct {
+	*client.Pool
+	addr string
+}
+
+func (p *poolCaller) Call(serviceMethod string, args, rly interface{}) error {
+	return p.Pool.Call(p.addr, serviceMethod, args, rly)
+}
+
+func newCaller(opt *options, name string, conns int) (caller, error) {
+	option := &codec.Option{MagicNumber: codec.MagicNum, CodecType: codecType(name)}
+	popt := &client.PoolOption{MaxConns: conns, MaxIdle: time.Minute}
+	if opt.addr != "" {
+		p, err := client.NewPool("tcp", popt, option)
+		if err != nil {
+			return nil, err
+		}
+		return &poolCaller{Pool: p, addr: opt.addr}, nil
+	}
+	d := discovery.NewServerDiscovery(opt.registry, 10*time.Second, &discovery.Option{Service: "Bench"})
+	return client.NewXClientWithPool("tcp", d, client.RoundRobinSelect, popt, option)
+}
+
+func bench(opt *options, name string, size, conns int) (*result, error) {
+	c, err := newCaller(opt, name, conns)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	payload := &service.Foo{Name: strings.Repeat("x", size)}
+	//预热，建立连接
+	if err := c.Call("Bench.Echo", payload, new(service.Foo)); err != nil {
+		return nil, err
+	}
+
+	res := &result{codec: name, size: size, conns: conns}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	start := time.Now()
+	deadline := start.Add(opt.duration)
+	for i := 0; i < opt.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			latency := make([]time.Duration, 0, 1024)
+			errs := 0
+			for time.Now().Before(deadline) {
+				begin := time.Now()
+				rly := new(service.Foo)
+				if err := c.Call("Bench.Echo", payload, rly); err != nil || len(rly.Name) != size {
+					errs++
+					continue
+				}
+				latency = append(latency, time.Since(begin))
+			}
+			mu.Lock()
+			res.latency = append(res.latency, latency...)
+			res.errors += errs
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	res.elapsed = time.Since(start)
+	res.requests = len(res.latency) + res.errors
+	sort.Slice(res.latency, func(i, j int) bool { return res.latency[i] < res.latency[j] })
+	return res, nil
+}
+
+// percentile 返回已排序的latency中第p百分位的值
+func percentile(latency []time.Duration, p float64) time.Duration {
+	if len(latency) == 0 {
+		return 0
+	}
+	i := int(float64(len(latency))*p/100+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(latency) {
+		i = len(latency) - 1
+	}
+	return latency[i]
+}
+
+func report(w io.Writer, results []*result) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "codec\tsize\tconns\trequests\terrors\tqps\tp50\tp90\tp99\tmax\t")
+	for _, r := range results {
+		qps := float64(len(r.latency)) / r.elapsed.Seconds()
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%.0f\t%v\t%v\t%v\t%v\t\n", r.codec, r.size, r.conns, r.requests, r.errors, qps,
+			percentile(r.latency, 50), percentile(r.latency, 90), percentile(r.latency, 99), percentile(r.latency, 100))
+	}
+	_ = tw.Flush()
+}
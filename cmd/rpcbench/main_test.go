@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	latency := make([]time.Duration, 100)
+	for i := range latency {
+		latency[i] = time.Duration(i+1) * time.Millisecond
+	}
+	cases := map[float64]time.Duration{50: 50 * time.Millisecond, 99: 99 * time.Millisecond, 100: 100 * time.Millisecond, 0: time.Millisecond}
+	for p, expect := range cases {
+		if got := percentile(latency, p); got != expect {
+			t.Fatalf("p%v: expect %v, got %v", p, expect, got)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Fatalf("expect 0 for empty latency, got %v", got)
+	}
+}
+
+func TestBench(t *testing.T) {
+	var out bytes.Buffer
+	if err := run([]string{"-duration", "50ms", "-concurrency", "4", "-codecs", "gob,proto", "-sizes", "8", "-conns", "2"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], "p99") || !strings.Contains(lines[2], "proto") {
+		t.Fatalf("unexpected report:\n%s", out.String())
+	}
+	if err := run([]string{"-codecs", "xml"}, &out); err == nil {
+		t.Fatal("expect unknown codec rejected")
+	}
+}
@@ -0,0 +1,194 @@
+// rpcctl 调试运行中的simplerpc服务器的命令行工具
+//
+//	rpcctl -addr 127.0.0.1:9999 list [Service]
+//	rpcctl -addr 127.0.0.1:9999 describe Service[.Method]
+//	rpcctl -registry http://127.0.0.1:9998/_rpc_/registry call Service.Method '{"A":1}'
+//
+// 直接连接-addr指定的服务器，或者通过-registry从注册中心发现提供该服务的服务器；
+// 调用参数为json，省略或为-时从标准输入读取，结果以json输出
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"simplerpc/client"
+	"simplerpc/codec"
+	"simplerpc/discovery"
+	"simplerpc/server"
+	"strings"
+	"time"
+)
+
+type options struct {
+	addr      string
+	registry  string
+	namespace string
+	token     string
+	timeout   time.Duration
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "rpcctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	opt := new(options)
+	fs := flag.NewFlagSet("rpcctl", flag.ContinueOnError)
+	fs.StringVar(&opt.addr, "addr", "", "server address, e.g. 127.0.0.1:9999")
+	fs.StringVar(&opt.registry, "registry", "", "registry url, used when -addr is empty")
+	fs.StringVar(&opt.namespace, "namespace", "", "registry namespace")
+	fs.StringVar(&opt.token, "token", "", "registry read token")
+	fs.DurationVar(&opt.timeout, "timeout", 5*time.Second, "timeout of each call")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: rpcctl [flags] list [Service] | describe Service[.Method] | call Service.Method [json|-]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if opt.addr == "" && opt.registry == "" {
+		return errors.New("-addr or -registry is required")
+	}
+	cmd, rest := fs.Arg(0), fs.Args()
+	if len(rest) > 0 {
+		rest = rest[1:]
+	}
+	switch cmd {
+	case "list":
+		return list(opt, rest, stdout)
+	case "describe":
+		return describe(opt, rest, stdout)
+	case "call":
+		return call(opt, rest, stdin, stdout)
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+// dial 连接提供service的服务器，统一使用json编码
+func (opt *options) dial(service string) (*client.Client, error) {
+	option := &codec.Option{MagicNumber: codec.MagicNum, CodecType: codec.JsonType}
+	if opt.addr != "" {
+		return client.DialTimeout("tcp", opt.addr, opt.timeout, option)
+	}
+	d := discovery.NewServerDiscovery(opt.registry, 0, &discovery.Option{Namespace: opt.namespace, Service: service, Token: opt.token})
+	return client.Dial("tcp", d, option)
+}
+
+// call 在提供service的服务器上调用serviceMethod
+func (opt *options) call(service, serviceMethod string, args, reply interface{}) error {
+	c, err := opt.dial(service)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Call(serviceMethod, args, reply)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(opt.timeout):
+		return fmt.Errorf("call %s timeout after %v", serviceMethod, opt.timeout)
+	}
+}
+
+func (opt *options) services(service string) ([]*server.ServiceInfo, error) {
+	reply := new(server.ReflectionResponse)
+	//通过注册中心发现时，查询某个服务要连接到提供该服务的实例；service为空时不过滤，
+	//内置的反射服务不会登记到注册中心，但任意实例都提供
+	if err := opt.call(service, server.ReflectionListMethod, &server.ReflectionRequest{Service: service}, reply); err != nil {
+		return nil, err
+	}
+	return reply.Services, nil
+}
+
+func list(opt *options, args []string, stdout io.Writer) error {
+	service := ""
+	if len(args) > 0 {
+		service = args[0]
+	}
+	services, err := opt.services(service)
+	if err != nil {
+		return err
+	}
+	for _, info := range services {
+		for _, m := range info.Methods {
+			fmt.Fprintf(stdout, "%s.%s(%s) returns (%s)\n", info.Name, m.Name, m.Arg.Name, m.Reply.Name)
+		}
+	}
+	return nil
+}
+
+func describe(opt *options, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("describe needs Service or Service.Method")
+	}
+	parts := strings.SplitN(args[0], ".", 2)
+	services, err := opt.services(parts[0])
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		return fmt.Errorf("service %s not found", parts[0])
+	}
+	var v interface{} = services[0]
+	if len(parts) == 2 {
+		v = nil
+		for _, m := range services[0].Methods {
+			if m.Name == parts[1] {
+				v = m
+			}
+		}
+		if v == nil {
+			return fmt.Errorf("method %s not found", args[0])
+		}
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func call(opt *options, args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 || !strings.Contains(args[0], ".") {
+		return errors.New("call needs Service.Method")
+	}
+	var data []byte
+	if len(args) < 2 || args[1] == "-" {
+		var err error
+		if data, err = io.ReadAll(stdin); err != nil {
+			return err
+		}
+	} else {
+		data = []byte(args[1])
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		data = []byte("null")
+	}
+	if !json.Valid(data) {
+		return errors.New("arguments are not valid json")
+	}
+
+	var reply json.RawMessage
+	service := strings.Split(args[0], ".")[0]
+	if err := opt.call(service, args[0], json.RawMessage(data), &reply); err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, reply, "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	_, err := out.WriteTo(stdout)
+	return err
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http/httptest"
+	"simplerpc/registry"
+	"simplerpc/server"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Args struct {
+	A, B int
+}
+
+type Arith int
+
+func (a *Arith) Add(args *Args, rly *int) error {
+	*rly = args.A + args.B
+	return nil
+}
+
+func startArith(t *testing.T) string {
+	s := server.NewServer()
+	if err := s.Registry(new(Arith)); err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Accept(lis)
+	t.Cleanup(s.Shutdown)
+	return lis.Addr().String()
+}
+
+func TestRpcctl(t *testing.T) {
+	addr := startArith(t)
+	exec := func(stdin string, args ...string) (string, error) {
+		var out bytes.Buffer
+		err := run(args, strings.NewReader(stdin), &out)
+		return out.String(), err
+	}
+
+	out, err := exec("", "-addr", addr, "list")
+	if err != nil || !strings.Contains(out, "Arith.Add(*main.Args) returns (*int)") || !strings.Contains(out, "Health.Check") {
+		t.Fatalf("unexpected list output: %q %v", out, err)
+	}
+	out, err = exec("", "-addr", addr, "describe", "Arith.Add")
+	if err != nil || !strings.Contains(out, `"Name": "A"`) {
+		t.Fatalf("unexpected describe output: %q %v", out, err)
+	}
+	if out, err = exec("", "-addr", addr, "call", "Arith.Add", `{"A":1,"B":2}`); err != nil || strings.TrimSpace(out) != "3" {
+		t.Fatalf("unexpected call output: %q %v", out, err)
+	}
+	if out, err = exec(`{"A":40,"B":2}`, "-addr", addr, "call", "Arith.Add", "-"); err != nil || strings.TrimSpace(out) != "42" {
+		t.Fatalf("unexpected call output from stdin: %q %v", out, err)
+	}
+	if _, err = exec("", "-addr", addr, "call", "Arith.Sub", `{}`); err == nil {
+		t.Fatal("expect error for unknown method")
+	}
+	if _, err = exec("", "-addr", addr, "call", "Arith.Add", `{bad`); err == nil {
+		t.Fatal("expect error for invalid json")
+	}
+
+	//通过注册中心发现服务器
+	r := registry.NewRegistry(time.Minute)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	//与服务器登记时一样，内置服务不在Services中
+	h := registry.StartHeartbeat(ts.URL, &registry.Instance{Addr: addr, Services: []string{"Arith"}}, time.Minute)
+	defer h.Stop()
+	time.Sleep(100 * time.Millisecond)
+	if out, err = exec("", "-registry", ts.URL, "call", "Arith.Add", `{"A":2,"B":2}`); err != nil || strings.TrimSpace(out) != "4" {
+		t.Fatalf("unexpected call output via registry: %q %v", out, err)
+	}
+	if out, err = exec("", "-registry", ts.URL, "list"); err != nil || !strings.Contains(out, "Arith.Add") || !strings.Contains(out, "Health.Check") {
+		t.Fatalf("unexpected list output via registry: %q %v", out, err)
+	}
+	if out, err = exec("", "-registry", ts.URL, "list", "Arith"); err != nil || !strings.Contains(out, "Arith.Add") || strings.Contains(out, "Health.Check") {
+		t.Fatalf("unexpected service list output via registry: %q %v", out, err)
+	}
+}
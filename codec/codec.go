@@ -36,6 +36,7 @@ var DefaultOption = &Option{
 //		ServiceMethod string
 //		Seq           uint64
 //		Err           string
+//		OneWay        bool   //单向调用，服务器不返回响应
 //	}
 type Header = service.Header
 type Body = service.Body
@@ -54,5 +55,6 @@ var CodecFuncTable map[Type]NewCodecFunc
 func init() {
 	CodecFuncTable = make(map[Type]NewCodecFunc)
 	CodecFuncTable[GobType] = NewGobCodec
+	CodecFuncTable[JsonType] = NewJsonCodec
 	CodecFuncTable[ProtoType] = NewProtoCodec
 }
@@ -1,6 +1,7 @@
 package codec
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"net"
@@ -11,14 +12,14 @@ import (
 func TestGobCodec(t *testing.T) {
 
 	var wg sync.WaitGroup
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatal(err)
+	}
 	wg.Add(1)
 	go func() {
 
 		defer wg.Done()
-		lis, err := net.Listen("tcp", ":9090")
-		if err != nil {
-			log.Fatal(err)
-		}
 		if conn, err := lis.Accept(); err == nil {
 
 			cc := NewGobCodec(conn)
@@ -28,7 +29,7 @@ func TestGobCodec(t *testing.T) {
 		}
 	}()
 
-	conn, err := net.Dial("tcp", ":9090")
+	conn, err := net.Dial("tcp", lis.Addr().String())
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -42,3 +43,88 @@ func TestGobCodec(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestJsonCodec(t *testing.T) {
+	client, server := net.Pipe()
+	go func() {
+		cc := NewJsonCodec(client)
+		_ = cc.WriteHeader(&Header{ServiceMethod: "Foo.Sum", Seq: 1})
+		_ = cc.WriteBody(json.RawMessage(`{"A":1}`))
+		_ = cc.WriteHeader(&Header{ServiceMethod: "Foo.Sum", Seq: 2})
+		_ = cc.WriteBody(map[string]int{"A": 2})
+	}()
+
+	cc := NewJsonCodec(server)
+	h := new(Header)
+	if err := cc.ReadHeader(h); err != nil || h.ServiceMethod != "Foo.Sum" || h.Seq != 1 {
+		t.Fatalf("unexpected header: %v %v", h, err)
+	}
+	//body为nil时丢弃
+	if err := cc.ReadBody(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := cc.ReadHeader(h); err != nil || h.Seq != 2 {
+		t.Fatalf("unexpected header after discarded body: %v %v", h, err)
+	}
+	var body struct{ A int }
+	if err := cc.ReadBody(&body); err != nil || body.A != 2 {
+		t.Fatalf("unexpected body: %v %v", body, err)
+	}
+}
+
+func TestOneWayHeader(t *testing.T) {
+	for typ, f := range CodecFuncTable {
+		client, server := net.Pipe()
+		go func() {
+			_ = f(client).WriteHeader(&Header{ServiceMethod: "Foo.Log", Seq: 3, OneWay: true})
+		}()
+		h := new(Header)
+		if err := f(server).ReadHeader(h); err != nil || !h.OneWay || h.Seq != 3 {
+			t.Fatalf("%s: unexpected header: %v %v", typ, h, err)
+		}
+		_ = client.Close()
+		_ = server.Close()
+	}
+}
+
+func TestProtoCodec(t *testing.T) {
+	client, server := net.Pipe()
+	writeErr := make(chan error, 1)
+	go func() {
+		cc := NewProtoCodec(client)
+		_ = cc.WriteHeader(&Header{ServiceMethod: "Foo.Sum", Seq: 1})
+		_ = cc.WriteBody(struct{}{})
+		_ = cc.WriteHeader(&Header{ServiceMethod: "Foo.Sum", Seq: 2})
+		writeErr <- cc.WriteBody(42)
+		_ = cc.WriteHeader(&Header{ServiceMethod: "Foo.Sum", Seq: 3})
+		_ = cc.WriteBody(&Header{Seq: 9})
+	}()
+
+	cc := NewProtoCodec(server)
+	h := new(Header)
+	if err := cc.ReadHeader(h); err != nil || h.Seq != 1 {
+		t.Fatalf("unexpected header: %v %v", h, err)
+	}
+	//出错时的空响应写成空帧，body为nil时丢弃
+	if err := cc.ReadBody(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := cc.ReadHeader(h); err != nil || h.Seq != 2 {
+		t.Fatalf("unexpected header: %v %v", h, err)
+	}
+	//非message对象在两端都报错，但不会让后续的帧错位
+	var n int
+	if err := cc.ReadBody(&n); err == nil {
+		t.Fatal("expect error reading into a non-message body")
+	}
+	if err := <-writeErr; err == nil {
+		t.Fatal("expect error writing a non-message body")
+	}
+	if err := cc.ReadHeader(h); err != nil || h.Seq != 3 {
+		t.Fatalf("unexpected header after rejected body: %v %v", h, err)
+	}
+	body := new(Header)
+	if err := cc.ReadBody(body); err != nil || body.Seq != 9 {
+		t.Fatalf("unexpected body: %v %v", body, err)
+	}
+}
@@ -0,0 +1,70 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+)
+
+// JsonCodec 请求头和请求体都编码为一行json，便于命令行工具等不知道参数类型的调用方
+// 直接发送json.RawMessage作为参数，并以json.RawMessage接收结果
+type JsonCodec struct {
+	conn io.ReadWriteCloser
+	buf  *bufio.Writer
+	enc  *json.Encoder
+	dec  *json.Decoder
+}
+
+func NewJsonCodec(conn io.ReadWriteCloser) Codec {
+	buf := bufio.NewWriter(conn)
+	return &JsonCodec{
+		conn: conn,
+		buf:  buf,
+		enc:  json.NewEncoder(buf),
+		dec:  json.NewDecoder(conn),
+	}
+}
+
+func (j *JsonCodec) Close() error {
+	return j.conn.Close()
+}
+
+func (j *JsonCodec) ReadHeader(header *Header) error {
+	err := j.dec.Decode(header)
+	if err != nil {
+		log.Println("codec error: read header err")
+	}
+	return err
+}
+
+func (j *JsonCodec) ReadBody(body interface{}) error {
+	if body == nil {
+		//body为nil时丢弃该请求体，例如出错的响应
+		var discard json.RawMessage
+		body = &discard
+	}
+	err := j.dec.Decode(body)
+	if err != nil {
+		log.Println("codec error: read body err")
+	}
+	return err
+}
+
+func (j *JsonCodec) WriteHeader(header *Header) error {
+	defer j.buf.Flush()
+	err := j.enc.Encode(header)
+	if err != nil {
+		log.Println("codec error: write header err")
+	}
+	return err
+}
+
+func (j *JsonCodec) WriteBody(body interface{}) error {
+	defer j.buf.Flush()
+	err := j.enc.Encode(body)
+	if err != nil {
+		log.Println("codec error: write body err")
+	}
+	return err
+}
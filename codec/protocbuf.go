@@ -3,6 +3,7 @@ package codec
 import (
 	"bufio"
 	"encoding/binary"
+	"fmt"
 	"google.golang.org/protobuf/proto"
 	"io"
 )
@@ -25,25 +26,29 @@ func (c *ProtoCodec) Close() error {
 
 func (c *ProtoCodec) ReadHeader(header *Header) error {
 	//将缓存的字节数组反序列化成message对象
-	byte_len := make([]byte, 8)
-	c.buf.Read(byte_len)
-	l, _ := binary.Uvarint(byte_len)
-	bytes := make([]byte, l)
-	c.buf.Read(bytes)
-	proto.Unmarshal(bytes, header)
-	return nil
+	bytes, err := c.readFrame()
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(bytes, header)
 }
 
 func (c *ProtoCodec) ReadBody(body any) error {
 	//fmt.Printf("bodyT:%T", body)
-	byte_len := make([]byte, 8)
-	c.buf.Read(byte_len)
-	l, _ := binary.Uvarint(byte_len)
-	bytes := make([]byte, l)
-	c.buf.Read(bytes)
+	bytes, err := c.readFrame()
+	if err != nil {
+		return err
+	}
 	//&Body{Data: anypb.New(reflect.ValueOf(body))}
-	proto.Unmarshal(bytes, body.(proto.Message))
-	return nil
+	//body为nil时丢弃该帧，例如出错的响应
+	if body == nil {
+		return nil
+	}
+	msg, ok := body.(proto.Message)
+	if !ok {
+		return fmt.Errorf("rpc codec: %T is not a proto.Message", body)
+	}
+	return proto.Unmarshal(bytes, msg)
 }
 
 func (c *ProtoCodec) WriteHeader(header *Header) error {
@@ -52,26 +57,50 @@ func (c *ProtoCodec) WriteHeader(header *Header) error {
 	if err != nil {
 		return err
 	}
-	byte_len := make([]byte, 8)
-	binary.PutUvarint(byte_len, uint64(len(byte_date)))
-	c.buf.Write(byte_len)
-	c.buf.Write(byte_date)
-	c.buf.Flush()
-	return nil
+	return c.writeFrame(byte_date)
 }
 
 func (c *ProtoCodec) WriteBody(body any) error {
 	//将message对象序列化到缓存中
-	byte_date, err := proto.Marshal(body.(proto.Message))
+	//nil和出错时的空响应struct{}{}写入一个空帧，保证读写两端的帧数一致
+	if body == nil || body == (struct{}{}) {
+		return c.writeFrame(nil)
+	}
+	msg, ok := body.(proto.Message)
+	if !ok {
+		//同样写入空帧，对端读到后按同样的原因报错，连接上后续的帧不会错位
+		if err := c.writeFrame(nil); err != nil {
+			return err
+		}
+		return fmt.Errorf("rpc codec: %T is not a proto.Message", body)
+	}
+	byte_date, err := proto.Marshal(msg)
 	if err != nil {
 		return err
 	}
+	return c.writeFrame(byte_date)
+}
+
+// readFrame 帧格式为8字节的uvarint长度加上数据，需要读满，否则会和后续的帧错位
+func (c *ProtoCodec) readFrame() ([]byte, error) {
+	byte_len := make([]byte, 8)
+	if _, err := io.ReadFull(c.buf, byte_len); err != nil {
+		return nil, err
+	}
+	l, _ := binary.Uvarint(byte_len)
+	bytes := make([]byte, l)
+	if _, err := io.ReadFull(c.buf, bytes); err != nil {
+		return nil, err
+	}
+	return bytes, nil
+}
+
+func (c *ProtoCodec) writeFrame(byte_date []byte) error {
 	byte_len := make([]byte, 8)
 	binary.PutUvarint(byte_len, uint64(len(byte_date)))
 	c.buf.Write(byte_len)
 	c.buf.Write(byte_date)
-	c.buf.Flush()
-	return nil
+	return c.buf.Flush()
 }
 
 //func (c *ProtoCodec) Read(r *service.AddRequest) error {
@@ -1,8 +1,13 @@
 package discovery
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -15,21 +20,68 @@ type Discovery interface {
 	Get() (string, error)
 	GetAll() ([]string, error)
 }
+
+// Remover 可以立即剔除已下线服务器的discovery，不必等到下一次刷新，XClient在连接失败时调用
+type Remover interface {
+	Remove(server string)
+}
+
+// InstanceDiscovery 可以返回带元数据实例列表的discovery，加权轮询等负载均衡需要实现该接口
+type InstanceDiscovery interface {
+	Discovery
+	GetInstances() ([]*Instance, error)
+}
+
+type Option struct {
+	Namespace string //只发现该命名空间的服务器，为空时为default，不同命名空间（如staging和prod）的服务器互不可见
+	Service   string //只发现提供该服务的服务器，为空时不过滤
+	Version   string //只发现该版本的服务器，为空时不过滤
+	Zone      string //优先发现该机房的服务器，机房内无可用服务器时使用其他机房
+	Token     string //注册中心要求读取认证时使用的令牌
+}
+
+var DefaultOption = &Option{}
+
+// fetchTimeout 请求注册中心的超时，节点接受连接后不响应时据此切换到下一个节点；
+// watch的长轮询在等待时间之外再留出同样的余量
+var fetchTimeout = 5 * time.Second
+
 type ServerDiscovery struct {
-	servers  []string
-	mu       sync.Mutex
-	index    uint64
-	registry string
-	timeout  time.Duration
-	lastTime time.Time
+	servers     []string
+	instances   []*Instance
+	mu          sync.Mutex
+	index       uint64
+	registries  []string //注册中心集群的所有节点
+	current     uint64   //上一次请求成功的节点下标
+	timeout     time.Duration
+	lastTime    time.Time
+	option      *Option
+	client      *http.Client //普通请求
+	watchClient *http.Client //长轮询请求
+
+	//watch模式下由后台长轮询保持服务列表最新
+	revision    uint64
+	watching    bool
+	watchTime   time.Time //上一次收到watch响应的时间
+	stopWatch   chan struct{}
+	subscribers []chan []*Instance
 }
 
-func NewServerDiscovery(registry string, timeout time.Duration) *ServerDiscovery {
+// NewServerDiscovery 创建从注册中心发现服务器的discovery
+// registry可以是以逗号分隔的多个注册中心节点，某个节点不可用时自动切换到下一个
+func NewServerDiscovery(registry string, timeout time.Duration, options ...*Option) *ServerDiscovery {
 
+	option := DefaultOption
+	if len(options) > 0 && options[0] != nil {
+		option = options[0]
+	}
 	d := &ServerDiscovery{
-		servers:  make([]string, 0),
-		registry: registry,
-		timeout:  timeout,
+		servers:     make([]string, 0),
+		registries:  splitRegistries(registry),
+		timeout:     timeout,
+		option:      option,
+		client:      &http.Client{Timeout: fetchTimeout},
+		watchClient: &http.Client{Timeout: watchWait + fetchTimeout},
 	}
 	//err := d.Refresh()
 	//if err != nil {
@@ -42,29 +94,136 @@ func NewServerDiscovery(registry string, timeout time.Duration) *ServerDiscovery
 func (d *ServerDiscovery) Refresh() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	if d.lastTime.Add(d.timeout).After(time.Now()) {
+	if (d.watching && d.revision > 0 && time.Since(d.watchTime) < watchQuiet) || d.lastTime.Add(d.timeout).After(time.Now()) {
 		return nil
 	}
-	log.Printf("rpc discovery: refresh servers from registry:%s", d.registry)
-	resp, err := http.Get(d.registry)
+	log.Printf("rpc discovery: refresh servers from registry:%s", d.registries[atomic.LoadUint64(&d.current)])
+	instances, revision, _, err := d.fetch(nil)
 	if err != nil {
 		return err
 	}
-	servers := strings.Split(resp.Header.Get("Rpc"), ",")
-	d.servers = make([]string, 0, len(servers))
+	d.setInstances(instances)
+	d.revision = revision
+	d.lastTime = time.Now()
+	return nil
+}
+
+// fetch 从注册中心拉取服务列表，query为附加的查询参数（如长轮询的index和wait）
+// 从上一次成功的节点开始依次尝试，返回实际响应的节点下标；
+// 各节点的revision互不相同，切换到其他节点时不带长轮询参数，立即拿到该节点的全量列表
+func (d *ServerDiscovery) fetch(query url.Values) ([]*Instance, uint64, uint64, error) {
+	start := atomic.LoadUint64(&d.current)
+	var err error
+	for i := 0; i < len(d.registries); i++ {
+		idx := (start + uint64(i)) % uint64(len(d.registries))
+		if i == 1 {
+			log.Printf("rpc discovery: registry:%s unavailable, fail over: %v", d.registries[start], err)
+			query = nil
+		}
+		var instances []*Instance
+		var revision uint64
+		if instances, revision, err = d.fetchFrom(d.registries[idx], query); err == nil {
+			atomic.StoreUint64(&d.current, idx)
+			return instances, revision, idx, nil
+		}
+	}
+	return nil, 0, start, err
+}
+
+func (d *ServerDiscovery) fetchFrom(registry string, query url.Values) ([]*Instance, uint64, error) {
+	req, err := http.NewRequest(http.MethodGet, d.registryURL(registry, query), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set(namespaceHeader, d.namespace())
+	if d.option.Token != "" {
+		req.Header.Set(tokenHeader, d.option.Token)
+	}
+	client := d.client
+	if query.Get("wait") != "" {
+		client = d.watchClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("rpc discovery: registry responded %s", resp.Status)
+	}
+	var instances []*Instance
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(resp.Body).Decode(&instances); err != nil {
+			return nil, 0, err
+		}
+	} else {
+		//旧版注册中心只在Rpc头中返回地址
+		instances = instancesOf(strings.Split(resp.Header.Get("Rpc"), ","))
+	}
+	revision, _ := strconv.ParseUint(resp.Header.Get("Rpc-Index"), 10, 64)
+	return filterInstances(instances, d.option), revision, nil
+}
+
+func splitRegistries(registry string) []string {
+	registries := make([]string, 0)
+	for _, r := range strings.Split(registry, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			registries = append(registries, r)
+		}
+	}
+	if len(registries) == 0 {
+		registries = append(registries, registry)
+	}
+	return registries
+}
+
+func instancesOf(servers []string) []*Instance {
+	instances := make([]*Instance, 0, len(servers))
 	for _, server := range servers {
 		if strings.TrimSpace(server) != "" {
-			d.servers = append(d.servers, strings.TrimSpace(server))
+			instances = append(instances, &Instance{Addr: strings.TrimSpace(server), Weight: 1})
 		}
 	}
-	d.lastTime = time.Now()
-	return nil
+	return instances
+}
+
+func (d *ServerDiscovery) setInstances(instances []*Instance) {
+	d.instances = instances
+	d.servers = make([]string, 0, len(instances))
+	for _, ins := range instances {
+		d.servers = append(d.servers, ins.Addr)
+	}
+}
+
+// registryURL 在注册中心地址上附加命名空间、按服务名过滤的查询参数以及query
+func (d *ServerDiscovery) registryURL(registry string, query url.Values) string {
+	u, err := url.Parse(registry)
+	if err != nil {
+		return registry
+	}
+	q := u.Query()
+	q.Set("namespace", d.namespace())
+	if d.option.Service != "" {
+		q.Set("service", d.option.Service)
+	}
+	for key, values := range query {
+		q[key] = values
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func (d *ServerDiscovery) namespace() string {
+	if d.option.Namespace == "" {
+		return defaultNamespace
+	}
+	return d.option.Namespace
 }
 
 func (d *ServerDiscovery) Update(servers []string) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	d.servers = servers
+	d.setInstances(instancesOf(servers))
 	d.lastTime = time.Now()
 
 }
@@ -73,6 +232,11 @@ func (d *ServerDiscovery) Get() (string, error) {
 	if err := d.Refresh(); err != nil {
 		return "", err
 	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.servers) == 0 {
+		return "", errors.New("rpc discovery: no available servers")
+	}
 	index := d.index % uint64(len(d.servers))
 	atomic.AddUint64(&d.index, 1)
 	return d.servers[index], nil
@@ -82,5 +246,32 @@ func (d *ServerDiscovery) GetAll() ([]string, error) {
 	if err := d.Refresh(); err != nil {
 		return []string{}, err
 	}
-	return d.servers, nil
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	servers := make([]string, len(d.servers))
+	copy(servers, d.servers)
+	return servers, nil
+}
+
+func (d *ServerDiscovery) GetInstances() ([]*Instance, error) {
+	if err := d.Refresh(); err != nil {
+		return []*Instance{}, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	instances := make([]*Instance, len(d.instances))
+	copy(instances, d.instances)
+	return instances, nil
+}
+
+func (d *ServerDiscovery) Remove(server string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	instances := make([]*Instance, 0, len(d.instances))
+	for _, ins := range d.instances {
+		if ins.Addr != server {
+			instances = append(instances, ins)
+		}
+	}
+	d.setInstances(instances)
 }
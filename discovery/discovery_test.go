@@ -0,0 +1,37 @@
+package discovery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetchTimeoutFailover(t *testing.T) {
+	timeout := fetchTimeout
+	fetchTimeout = 200 * time.Millisecond
+	defer func() { fetchTimeout = timeout }()
+
+	//第一个节点接受连接后一直不响应
+	hang := make(chan struct{})
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-hang
+	}))
+	defer hung.Close()
+	defer close(hang)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode([]*Instance{{Addr: "127.0.0.1:8001", Weight: 1}})
+	}))
+	defer ts.Close()
+
+	d := NewServerDiscovery(hung.URL+","+ts.URL, 0)
+	start := time.Now()
+	if got, err := d.Get(); err != nil || got != "127.0.0.1:8001" {
+		t.Fatalf("expect failover from hung registry, got %s %v", got, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expect failover bounded by fetch timeout, took %v", elapsed)
+	}
+}
@@ -0,0 +1,153 @@
+package discovery
+
+import (
+	"errors"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Hash 将key映射到哈希环上的位置
+type Hash func(data []byte) uint32
+
+const defaultReplicas = 100
+
+// HashRing 一致性哈希环
+// 每个真实节点在环上对应replicas个虚拟节点，节点增删时只有相邻区间的key会被重新映射
+type HashRing struct {
+	hash     Hash
+	replicas int
+
+	mu      sync.RWMutex
+	keys    []uint32          //排序后的虚拟节点位置
+	nodes   map[uint32]string //虚拟节点位置 -> 真实节点
+	members map[string]struct{}
+}
+
+func NewHashRing(replicas int, fn Hash) *HashRing {
+	if replicas <= 0 {
+		replicas = defaultReplicas
+	}
+	if fn == nil {
+		fn = crc32.ChecksumIEEE
+	}
+	return &HashRing{
+		hash:     fn,
+		replicas: replicas,
+		nodes:    make(map[uint32]string),
+		members:  make(map[string]struct{}),
+	}
+}
+
+func (h *HashRing) Add(nodes ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.add(nodes...)
+	h.sortKeys()
+}
+
+func (h *HashRing) Remove(nodes ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(nodes...)
+}
+
+// Set 将环上的节点调整为nodes，只增删发生变化的节点，未变化的节点位置保持不动
+func (h *HashRing) Set(nodes []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	want := make(map[string]struct{}, len(nodes))
+	added := make([]string, 0)
+	for _, node := range nodes {
+		want[node] = struct{}{}
+		if _, ok := h.members[node]; !ok {
+			added = append(added, node)
+		}
+	}
+	removed := make([]string, 0)
+	for node := range h.members {
+		if _, ok := want[node]; !ok {
+			removed = append(removed, node)
+		}
+	}
+	if len(removed) > 0 {
+		h.remove(removed...)
+	}
+	if len(added) > 0 {
+		h.add(added...)
+		h.sortKeys()
+	}
+}
+
+// Get 顺时针找到key之后的第一个虚拟节点，返回其对应的真实节点
+func (h *HashRing) Get(key string) (string, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.keys) == 0 {
+		return "", errors.New("rpc discovery: hash ring is empty")
+	}
+	pos := h.hash([]byte(key))
+	idx := sort.Search(len(h.keys), func(i int) bool { return h.keys[i] >= pos })
+	return h.nodes[h.keys[idx%len(h.keys)]], nil
+}
+
+func (h *HashRing) Members() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	members := make([]string, 0, len(h.members))
+	for node := range h.members {
+		members = append(members, node)
+	}
+	sort.Strings(members)
+	return members
+}
+
+func (h *HashRing) add(nodes ...string) {
+	for _, node := range nodes {
+		if _, ok := h.members[node]; ok {
+			continue
+		}
+		h.members[node] = struct{}{}
+		h.place(node)
+	}
+}
+
+// place 放置node的虚拟节点，位置冲突时由名字较小的节点占有，
+// 这样环的状态只取决于节点集合，与节点加入的先后顺序无关，不同客户端的映射保持一致
+func (h *HashRing) place(node string) {
+	for i := 0; i < h.replicas; i++ {
+		pos := h.hash([]byte(node + "#" + strconv.Itoa(i)))
+		owner, ok := h.nodes[pos]
+		if !ok {
+			h.keys = append(h.keys, pos)
+		}
+		if !ok || node < owner {
+			h.nodes[pos] = node
+		}
+	}
+}
+
+// remove 被移除节点占有的位置可能与其他节点冲突过，需要重新放置剩余节点
+func (h *HashRing) remove(nodes ...string) {
+	removed := false
+	for _, node := range nodes {
+		if _, ok := h.members[node]; ok {
+			delete(h.members, node)
+			removed = true
+		}
+	}
+	if !removed {
+		return
+	}
+	h.keys = h.keys[:0]
+	h.nodes = make(map[uint32]string, len(h.nodes))
+	for node := range h.members {
+		h.place(node)
+	}
+	h.sortKeys()
+}
+
+func (h *HashRing) sortKeys() {
+	sort.Slice(h.keys, func(i, j int) bool { return h.keys[i] < h.keys[j] })
+}
@@ -0,0 +1,86 @@
+package discovery
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHashRing(t *testing.T) {
+	ring := NewHashRing(0, nil)
+	if _, err := ring.Get("user-1"); err == nil {
+		t.Fatal("expect error on empty ring")
+	}
+	ring.Set([]string{"127.0.0.1:8001", "127.0.0.1:8002", "127.0.0.1:8003"})
+
+	before := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := "user-" + strconv.Itoa(i)
+		addr, err := ring.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again, _ := ring.Get(key); again != addr {
+			t.Fatalf("key %s is not sticky: %s != %s", key, addr, again)
+		}
+		before[key] = addr
+	}
+
+	//移除一个节点后，只有原本落在该节点上的key会被重新映射
+	ring.Set([]string{"127.0.0.1:8001", "127.0.0.1:8003"})
+	for key, addr := range before {
+		now, _ := ring.Get(key)
+		if addr != "127.0.0.1:8002" && now != addr {
+			t.Fatalf("key %s remapped from %s to %s", key, addr, now)
+		}
+		if now == "127.0.0.1:8002" {
+			t.Fatalf("key %s still mapped to removed node", key)
+		}
+	}
+
+	//新增节点时，已有的key要么保持不变，要么迁移到新节点
+	ring.Set([]string{"127.0.0.1:8001", "127.0.0.1:8002", "127.0.0.1:8003", "127.0.0.1:8004"})
+	moved := 0
+	for key, addr := range before {
+		now, _ := ring.Get(key)
+		if now != addr && now != "127.0.0.1:8004" {
+			t.Fatalf("key %s remapped from %s to %s", key, addr, now)
+		}
+		if now != addr {
+			moved++
+		}
+	}
+	if moved == 0 || moved > len(before)/2 {
+		t.Fatalf("unexpected remapped keys: %d", moved)
+	}
+	if len(ring.Members()) != 4 {
+		t.Fatalf("expect 4 members, got %v", ring.Members())
+	}
+}
+
+func TestHashRingCollision(t *testing.T) {
+	//只按虚拟节点序号哈希，所有节点的虚拟节点位置都冲突
+	fn := func(data []byte) uint32 {
+		s := string(data)
+		n, _ := strconv.Atoi(s[strings.LastIndexByte(s, '#')+1:])
+		return uint32(n) * 1000
+	}
+	a, b := NewHashRing(10, fn), NewHashRing(10, fn)
+	a.Add("node-1")
+	a.Add("node-2")
+	b.Add("node-2")
+	b.Add("node-1")
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i * 97)
+		if x, _ := a.Get(key); x != "node-1" {
+			t.Fatalf("expect lower node to own collided positions, got %s", x)
+		}
+		if x, _ := b.Get(key); x != "node-1" {
+			t.Fatalf("expect mapping independent of join order, got %s", x)
+		}
+	}
+	a.Remove("node-1")
+	if x, err := a.Get("1"); err != nil || x != "node-2" {
+		t.Fatalf("expect remaining node to take over collided positions, got %s, %v", x, err)
+	}
+}
@@ -0,0 +1,52 @@
+package discovery
+
+// Instance 注册中心返回的服务器实例及其元数据，与registry.Instance的json格式一致
+type Instance struct {
+	Addr      string   `json:"addr"`
+	Namespace string   `json:"namespace,omitempty"`
+	Services  []string `json:"services,omitempty"`
+	Weight    int      `json:"weight"`
+	Version   string   `json:"version,omitempty"`
+	Zone      string   `json:"zone,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	Codecs    []string `json:"codecs,omitempty"`
+}
+
+const (
+	defaultNamespace = "default"
+	namespaceHeader  = "Rpc-Namespace"
+	tokenHeader      = "Rpc-Token"
+)
+
+// filterInstances 按Option做命名空间隔离、版本锁定和同机房优先
+// 注册中心已经按命名空间过滤，这里再检查一次，保证其他命名空间的实例不会被选中；
+// 设置了Zone时，若该机房内没有可用实例则退回到所有机房
+func filterInstances(instances []*Instance, option *Option) []*Instance {
+	namespace := option.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	matched := make([]*Instance, 0, len(instances))
+	for _, ins := range instances {
+		if ins.Namespace != namespace && !(ins.Namespace == "" && namespace == defaultNamespace) {
+			continue
+		}
+		if option.Version != "" && ins.Version != option.Version {
+			continue
+		}
+		matched = append(matched, ins)
+	}
+	if option.Zone == "" {
+		return matched
+	}
+	local := make([]*Instance, 0, len(matched))
+	for _, ins := range matched {
+		if ins.Zone == option.Zone {
+			local = append(local, ins)
+		}
+	}
+	if len(local) == 0 {
+		return matched
+	}
+	return local
+}
@@ -0,0 +1,119 @@
+package discovery
+
+import (
+	"log"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+const (
+	watchWait    = 30 * time.Second
+	watchBackoff = time.Second
+)
+
+// watchQuiet 超过该时长没有收到watch响应时认为watch已停滞，Refresh回到按timeout定期刷新
+var watchQuiet = 2 * watchWait
+
+// Watch 启动后台长轮询，注册中心上实例加入、离开或修改元数据时立即更新服务列表并通知订阅者
+// 返回的函数用于停止watch，停止后回到按timeout定期刷新
+func (d *ServerDiscovery) Watch() (stop func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.watching {
+		return d.StopWatch
+	}
+	d.watching = true
+	d.stopWatch = make(chan struct{})
+	go d.watchLoop(d.stopWatch)
+	return d.StopWatch
+}
+
+func (d *ServerDiscovery) StopWatch() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.watching {
+		return
+	}
+	d.watching = false
+	d.lastTime = time.Time{}
+	close(d.stopWatch)
+}
+
+// Subscribe 返回的channel在服务列表发生变化时收到最新的实例列表
+// channel只缓存最近一次的结果，订阅者处理不及时会跳过中间状态
+func (d *ServerDiscovery) Subscribe() <-chan []*Instance {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ch := make(chan []*Instance, 1)
+	d.subscribers = append(d.subscribers, ch)
+	return ch
+}
+
+func (d *ServerDiscovery) watchLoop(stop chan struct{}) {
+	var revision, source uint64
+	published := false
+	for {
+		query := url.Values{}
+		//第一次请求不带index，立即拿到全量列表和当前revision
+		if revision > 0 {
+			query.Set("index", strconv.FormatUint(revision, 10))
+			query.Set("wait", watchWait.String())
+		}
+		instances, next, from, err := d.fetch(query)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if err != nil {
+			log.Printf("rpc discovery: watch registries:%v error:%v", d.registries, err)
+			select {
+			case <-stop:
+				return
+			case <-time.After(watchBackoff):
+			}
+			continue
+		}
+		d.mu.Lock()
+		d.watchTime = time.Now()
+		//注册中心的revision是全局的，其他服务的变化也会使其增加，列表没有变化时不通知订阅者
+		changed := !published || from != source || !sameInstances(d.instances, instances)
+		d.mu.Unlock()
+		if changed {
+			d.publish(instances, next)
+			published = true
+		}
+		if next == 0 {
+			//注册中心不支持watch，退化为定期轮询
+			select {
+			case <-stop:
+				return
+			case <-time.After(watchBackoff):
+			}
+		}
+		revision, source = next, from
+	}
+}
+
+func (d *ServerDiscovery) publish(instances []*Instance, revision uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.setInstances(instances)
+	d.revision = revision
+	d.lastTime = time.Now()
+	for _, ch := range d.subscribers {
+		snapshot := make([]*Instance, len(instances))
+		copy(snapshot, instances)
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- snapshot
+	}
+}
+
+func sameInstances(a, b []*Instance) bool {
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,55 @@
+package discovery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchQuietFallback(t *testing.T) {
+	quiet := watchQuiet
+	watchQuiet = 200 * time.Millisecond
+	defer func() { watchQuiet = quiet }()
+
+	//长轮询请求一直挂起，模拟停滞的watch；普通请求返回当前列表
+	var mu sync.Mutex
+	addr := "127.0.0.1:8001"
+	stall := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("index") != "" {
+			<-stall
+			return
+		}
+		mu.Lock()
+		instances := []*Instance{{Addr: addr, Weight: 1}}
+		mu.Unlock()
+		w.Header().Set("Rpc-Index", "1")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(instances)
+	}))
+	defer ts.Close()
+	defer close(stall)
+
+	d := NewServerDiscovery(ts.URL, 10*time.Millisecond)
+	updates := d.Subscribe()
+	defer d.Watch()()
+	select {
+	case <-updates:
+	case <-time.After(time.Second):
+		t.Fatal("no initial list")
+	}
+
+	mu.Lock()
+	addr = "127.0.0.1:8002"
+	mu.Unlock()
+	if got, _ := d.Get(); got != "127.0.0.1:8001" {
+		t.Fatalf("expect list served by watch while it is fresh, got %s", got)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if got, err := d.Get(); err != nil || got != "127.0.0.1:8002" {
+		t.Fatalf("expect refresh to poll after watch went quiet, got %s, %v", got, err)
+	}
+}
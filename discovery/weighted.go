@@ -0,0 +1,44 @@
+package discovery
+
+import (
+	"errors"
+	"sync"
+)
+
+// WeightedRoundRobin 平滑加权轮询
+// 每次选择时所有实例的当前权重加上自身权重，选出当前权重最大的实例后减去总权重，
+// 权重越大的实例被选中越多，且不会被连续集中选中
+type WeightedRoundRobin struct {
+	mu      sync.Mutex
+	current map[string]int
+}
+
+func NewWeightedRoundRobin() *WeightedRoundRobin {
+	return &WeightedRoundRobin{current: make(map[string]int)}
+}
+
+func (w *WeightedRoundRobin) Next(instances []*Instance) (*Instance, error) {
+	if len(instances) == 0 {
+		return nil, errors.New("rpc discovery: no available servers")
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var best *Instance
+	total := 0
+	alive := make(map[string]int, len(instances))
+	for _, ins := range instances {
+		weight := ins.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		total += weight
+		alive[ins.Addr] = w.current[ins.Addr] + weight
+		if best == nil || alive[ins.Addr] > alive[best.Addr] {
+			best = ins
+		}
+	}
+	alive[best.Addr] -= total
+	//只保留仍然存在的实例，下线实例的状态随之丢弃
+	w.current = alive
+	return best, nil
+}
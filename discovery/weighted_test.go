@@ -0,0 +1,45 @@
+package discovery
+
+import "testing"
+
+func TestWeightedRoundRobin(t *testing.T) {
+	w := NewWeightedRoundRobin()
+	instances := []*Instance{
+		{Addr: "a", Weight: 5},
+		{Addr: "b", Weight: 1},
+		{Addr: "c", Weight: 1},
+	}
+	count := make(map[string]int)
+	seq := ""
+	for i := 0; i < 7; i++ {
+		ins, err := w.Next(instances)
+		if err != nil {
+			t.Fatal(err)
+		}
+		count[ins.Addr]++
+		seq += ins.Addr
+	}
+	if count["a"] != 5 || count["b"] != 1 || count["c"] != 1 {
+		t.Fatalf("unexpected distribution: %v", count)
+	}
+	if seq != "aabacaa" {
+		t.Fatalf("expect smooth sequence aabacaa, got %s", seq)
+	}
+}
+
+func TestFilterInstances(t *testing.T) {
+	instances := []*Instance{
+		{Addr: "a", Version: "v1", Zone: "sh"},
+		{Addr: "b", Version: "v2", Zone: "sh"},
+		{Addr: "c", Version: "v1", Zone: "bj"},
+	}
+	if got := filterInstances(instances, &Option{Version: "v1"}); len(got) != 2 {
+		t.Fatalf("expect 2 v1 instances, got %d", len(got))
+	}
+	if got := filterInstances(instances, &Option{Version: "v1", Zone: "bj"}); len(got) != 1 || got[0].Addr != "c" {
+		t.Fatalf("expect zone bj preferred, got %v", got)
+	}
+	if got := filterInstances(instances, &Option{Zone: "gz"}); len(got) != 3 {
+		t.Fatalf("expect fallback to all zones, got %d", len(got))
+	}
+}
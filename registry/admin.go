@@ -0,0 +1,256 @@
+package registry
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 注册中心的管理后台，挂在/admin下：
+//
+//	GET  /admin                             服务和实例的看板页面
+//	GET  /admin/api/instances               所有实例的状态，json格式
+//	POST /admin/instances/{addr}/evict      立即剔除实例，实例仍在发心跳时会被重新登记
+//	POST /admin/instances/{addr}/drain      摘除流量，实例保持登记但不再返回给discovery
+//	POST /admin/instances/{addr}/undrain    恢复流量
+//
+// 看板展示所有命名空间，操作实例时用?namespace=指定命名空间
+const adminPrefix = "/admin"
+
+const (
+	statusUp        = "UP"
+	statusLate      = "LATE" //超过一半超时时间没有收到心跳
+	statusDraining  = "DRAINING"
+	statusUnhealthy = "UNHEALTHY" //主动健康检查失败
+)
+
+type instanceView struct {
+	Instance
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+	Status        string    `json:"status"`
+	Draining      bool      `json:"draining"`
+}
+
+type serviceView struct {
+	Namespace string          `json:"namespace"`
+	Name      string          `json:"name"`
+	Instances []*instanceView `json:"instances"`
+}
+
+// instanceViews 返回包括已摘除流量在内的所有存活实例及其状态
+func (r *Registry) instanceViews() []*instanceView {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire()
+	now := time.Now()
+	views := make([]*instanceView, 0, len(r.kv))
+	for _, item := range r.kv {
+		view := &instanceView{Instance: item.Instance, LastHeartbeat: item.start, Draining: item.draining, Status: statusUp}
+		switch {
+		case item.draining:
+			view.Status = statusDraining
+		case item.unhealthy:
+			view.Status = statusUnhealthy
+		case r.timeout > 0 && now.Sub(item.start) > r.timeout/2:
+			view.Status = statusLate
+		}
+		views = append(views, view)
+	}
+	sort.Slice(views, func(i, j int) bool { return views[i].key() < views[j].key() })
+	return views
+}
+
+// setDraining 设置实例是否摘除流量，实例不存在时返回false
+func (r *Registry) setDraining(namespace, addr string, draining bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	item, ok := r.kv[instanceKey(namespace, addr)]
+	if !ok {
+		return false
+	}
+	if item.draining != draining {
+		item.draining = draining
+		r.persist(drainRecord(namespace, addr, draining))
+		r.notify()
+	}
+	return true
+}
+
+func groupByService(views []*instanceView) []*serviceView {
+	groups := make(map[string]*serviceView)
+	for _, view := range views {
+		names := view.Services
+		if len(names) == 0 {
+			names = []string{"-"}
+		}
+		for _, name := range names {
+			key := instanceKey(view.Namespace, name)
+			if groups[key] == nil {
+				groups[key] = &serviceView{Namespace: namespaceOf(view.Namespace), Name: name}
+			}
+			groups[key].Instances = append(groups[key].Instances, view)
+		}
+	}
+	services := make([]*serviceView, 0, len(groups))
+	for _, group := range groups {
+		services = append(services, group)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Namespace != services[j].Namespace {
+			return services[i].Namespace < services[j].Namespace
+		}
+		return services[i].Name < services[j].Name
+	})
+	return services
+}
+
+func (r *Registry) adminHandler() http.Handler {
+	r.adminOnce.Do(func() {
+		r.admin = r.newAdmin()
+	})
+	return r.admin
+}
+
+func (r *Registry) newAdmin() *gin.Engine {
+	//gin默认的debug模式会向标准输出打印所有路由
+	gin.SetMode(gin.ReleaseMode)
+	engine := gin.New()
+	engine.Use(gin.Recovery(), r.adminAuth)
+	engine.SetHTMLTemplate(adminTemplate)
+
+	admin := engine.Group(adminPrefix)
+	admin.GET("", r.adminIndex)
+	admin.GET("/", r.adminIndex)
+	admin.GET("/api/instances", func(c *gin.Context) {
+		c.JSON(http.StatusOK, r.instanceViews())
+	})
+	admin.POST("/instances/:addr/evict", func(c *gin.Context) {
+		namespace, addr := namespaceOf(c.Query("namespace")), c.Param("addr")
+		if !r.removeServer(namespace, addr) {
+			r.adminError(c, http.StatusNotFound, "instance not found: "+addr)
+			return
+		}
+		r.replicate(c.Request, &record{Op: opDelete, Namespace: namespace, Addr: addr})
+		r.adminDone(c)
+	})
+	admin.POST("/instances/:addr/drain", func(c *gin.Context) {
+		r.adminDrain(c, true)
+	})
+	admin.POST("/instances/:addr/undrain", func(c *gin.Context) {
+		r.adminDrain(c, false)
+	})
+	return engine
+}
+
+// adminAuth 开启认证后管理后台需要写权限，浏览器中以HTTP Basic认证的密码作为写令牌
+func (r *Registry) adminAuth(c *gin.Context) {
+	if _, password, ok := c.Request.BasicAuth(); ok && c.GetHeader(tokenHeader) == "" {
+		c.Request.Header.Set(tokenHeader, password)
+	}
+	if err := r.authorize(c.Request, true); err != nil {
+		c.Header("WWW-Authenticate", `Basic realm="rpc registry"`)
+		r.reject(c.Writer, c.Request, err)
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+func (r *Registry) adminIndex(c *gin.Context) {
+	views := r.instanceViews()
+	c.HTML(http.StatusOK, "admin", gin.H{
+		"Services":  groupByService(views),
+		"Instances": len(views),
+		"Timeout":   r.timeout,
+		"Now":       time.Now(),
+	})
+}
+
+func (r *Registry) adminDrain(c *gin.Context, draining bool) {
+	namespace, addr := namespaceOf(c.Query("namespace")), c.Param("addr")
+	if !r.setDraining(namespace, addr, draining) {
+		r.adminError(c, http.StatusNotFound, "instance not found: "+addr)
+		return
+	}
+	r.replicate(c.Request, drainRecord(namespace, addr, draining))
+	r.adminDone(c)
+}
+
+func drainRecord(namespace, addr string, draining bool) *record {
+	op := opUndrain
+	if draining {
+		op = opDrain
+	}
+	return &record{Op: op, Namespace: namespace, Addr: addr}
+}
+
+// adminDone 页面上的表单提交后回到看板，其他调用方返回json
+func (r *Registry) adminDone(c *gin.Context) {
+	if c.ContentType() == "application/x-www-form-urlencoded" {
+		c.Redirect(http.StatusSeeOther, adminPrefix)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (r *Registry) adminError(c *gin.Context, status int, msg string) {
+	c.JSON(status, &apiError{Error: msg})
+}
+
+var adminTemplate = template.Must(template.New("admin").Funcs(template.FuncMap{
+	"path": url.PathEscape,
+	"ago": func(now, t time.Time) string {
+		return now.Sub(t).Truncate(time.Millisecond).String()
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>simplerpc registry</title>
+<style>
+body { font-family: sans-serif; margin: 24px; }
+table { border-collapse: collapse; margin-bottom: 24px; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+.UP { color: green; } .LATE { color: orange; } .DRAINING { color: gray; } .UNHEALTHY { color: red; }
+form { display: inline; }
+</style>
+</head>
+<body>
+<h1>simplerpc registry</h1>
+<p>{{.Instances}} instances, heartbeat timeout {{.Timeout}}</p>
+{{range .Services}}
+<h2>{{.Namespace}} / {{.Name}}</h2>
+<table>
+<tr><th>address</th><th>status</th><th>last heartbeat</th><th>weight</th><th>version</th><th>zone</th><th>tags</th><th>codecs</th><th>services</th><th></th></tr>
+{{range .Instances}}
+<tr>
+<td>{{.Addr}}</td>
+<td class="{{.Status}}">{{.Status}}</td>
+<td>{{ago $.Now .LastHeartbeat}} ago</td>
+<td>{{.Weight}}</td>
+<td>{{.Version}}</td>
+<td>{{.Zone}}</td>
+<td>{{range .Tags}}{{.}} {{end}}</td>
+<td>{{range .Codecs}}{{.}} {{end}}</td>
+<td>{{range .Services}}{{.}} {{end}}</td>
+<td>
+{{if .Draining}}
+<form method="post" action="/admin/instances/{{path .Addr}}/undrain?namespace={{.Namespace}}"><button>undrain</button></form>
+{{else}}
+<form method="post" action="/admin/instances/{{path .Addr}}/drain?namespace={{.Namespace}}"><button>drain</button></form>
+{{end}}
+<form method="post" action="/admin/instances/{{path .Addr}}/evict?namespace={{.Namespace}}"><button>evict</button></form>
+</td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p>no instances registered</p>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,64 @@
+package registry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdmin(t *testing.T) {
+	r := NewRegistry(time.Minute)
+	r.putServer(&Instance{Addr: "127.0.0.1:8001", Services: []string{"FDD"}, Weight: 3, Version: "v1.2.0"})
+	r.putServer(&Instance{Addr: "127.0.0.1:8002", Services: []string{"FDD"}, Weight: defaultWeight})
+	do := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	w := do("GET", "/admin")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "127.0.0.1:8001") || !strings.Contains(w.Body.String(), "v1.2.0") {
+		t.Fatalf("unexpected dashboard: %d %s", w.Code, w.Body)
+	}
+
+	if w := do("POST", "/admin/instances/"+url.PathEscape("127.0.0.1:8001")+"/drain"); w.Code != http.StatusOK {
+		t.Fatalf("expect 200 on drain, got %d: %s", w.Code, w.Body)
+	}
+	if servers := r.aliveServers(DefaultNamespace, "FDD"); len(servers) != 1 || servers[0].Addr != "127.0.0.1:8002" {
+		t.Fatalf("expect drained instance out of rotation, got %v", servers)
+	}
+	var views []*instanceView
+	_ = json.NewDecoder(do("GET", "/admin/api/instances").Body).Decode(&views)
+	if len(views) != 2 || views[0].Status != statusDraining || views[1].Status != statusUp {
+		t.Fatalf("unexpected instance views: %+v", views)
+	}
+	//心跳续约不会取消摘除
+	r.putServer(&Instance{Addr: "127.0.0.1:8001", Services: []string{"FDD"}, Weight: 3, Version: "v1.2.0"})
+	if servers := r.aliveServers(DefaultNamespace, "FDD"); len(servers) != 1 {
+		t.Fatalf("expect instance still drained after heartbeat, got %v", servers)
+	}
+	if w := do("POST", "/admin/instances/127.0.0.1:8001/undrain"); w.Code != http.StatusOK {
+		t.Fatalf("expect 200 on undrain, got %d", w.Code)
+	}
+	if servers := r.aliveServers(DefaultNamespace, "FDD"); len(servers) != 2 {
+		t.Fatalf("expect instance back in rotation, got %v", servers)
+	}
+
+	req := httptest.NewRequest("POST", "/admin/instances/127.0.0.1:8002/evict", strings.NewReader(""))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != adminPrefix {
+		t.Fatalf("expect redirect after form evict, got %d", w.Code)
+	}
+	if servers := r.aliveServers(DefaultNamespace, "FDD"); len(servers) != 1 || servers[0].Addr != "127.0.0.1:8001" {
+		t.Fatalf("expect evicted instance removed, got %v", servers)
+	}
+	if w := do("POST", "/admin/instances/127.0.0.1:8002/drain"); w.Code != http.StatusNotFound {
+		t.Fatalf("expect 404 for unknown instance, got %d", w.Code)
+	}
+}
@@ -0,0 +1,160 @@
+package registry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// json格式的注册中心接口，与基于Rpc头的旧协议并存
+//
+//	GET    /v1/services                   列出所有服务及其实例数
+//	GET    /v1/services/{name}/instances  列出提供该服务的实例
+//	GET    /v1/instances                  列出所有实例，可用?service=过滤
+//	POST   /v1/instances                  登记或续约实例，请求体为Instance
+//	GET    /v1/instances/{addr}           查询单个实例
+//	DELETE /v1/instances/{addr}           注销实例
+//
+// 读取接口用?namespace=指定命名空间，默认为DefaultNamespace，见readNamespace；
+// 列表接口支持?index=&wait=长轮询，见watch
+const apiPrefix = "/v1/"
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+type serviceSummary struct {
+	Name      string `json:"name"`
+	Instances int    `json:"instances"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, &apiError{Error: msg})
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow ...string) {
+	w.Header().Set("Allow", strings.Join(allow, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+func (r *Registry) serveAPI(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, apiPrefix), "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "services":
+		if req.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		namespace, ok := r.readNamespace(w, req)
+		if !ok {
+			return
+		}
+		writeJSON(w, http.StatusOK, r.services(namespace))
+	case len(parts) == 3 && parts[0] == "services" && parts[2] == "instances":
+		if req.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		name, err := url.PathUnescape(parts[1])
+		if err != nil || name == "" {
+			writeError(w, http.StatusBadRequest, "invalid service name")
+			return
+		}
+		namespace, ok := r.readNamespace(w, req)
+		if !ok {
+			return
+		}
+		r.watch(w, req, namespace, name)
+		writeJSON(w, http.StatusOK, r.aliveServers(namespace, name))
+	case len(parts) == 1 && parts[0] == "instances":
+		switch req.Method {
+		case http.MethodGet:
+			namespace, ok := r.readNamespace(w, req)
+			if !ok {
+				return
+			}
+			service := req.URL.Query().Get("service")
+			r.watch(w, req, namespace, service)
+			writeJSON(w, http.StatusOK, r.aliveServers(namespace, service))
+		case http.MethodPost:
+			r.apiRegister(w, req)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPost)
+		}
+	case len(parts) == 2 && parts[0] == "instances":
+		addr, err := url.PathUnescape(parts[1])
+		if err != nil || addr == "" {
+			writeError(w, http.StatusBadRequest, "invalid instance address")
+			return
+		}
+		switch req.Method {
+		case http.MethodGet:
+			namespace, ok := r.readNamespace(w, req)
+			if !ok {
+				return
+			}
+			ins, ok := r.getServer(namespace, addr)
+			if !ok {
+				writeError(w, http.StatusNotFound, "instance not found: "+addr)
+				return
+			}
+			writeJSON(w, http.StatusOK, ins)
+		case http.MethodDelete:
+			namespace := namespaceOf(req.URL.Query().Get("namespace"))
+			removed := r.removeServer(namespace, addr)
+			r.replicate(req, &record{Op: opDelete, Namespace: namespace, Addr: addr})
+			if !removed {
+				writeError(w, http.StatusNotFound, "instance not found: "+addr)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodDelete)
+		}
+	default:
+		writeError(w, http.StatusNotFound, "no such endpoint: "+req.URL.Path)
+	}
+}
+
+func (r *Registry) apiRegister(w http.ResponseWriter, req *http.Request) {
+	ins := new(Instance)
+	if err := json.NewDecoder(req.Body).Decode(ins); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid instance: "+err.Error())
+		return
+	}
+	if ins.Addr == "" {
+		writeError(w, http.StatusBadRequest, "instance addr is required")
+		return
+	}
+	ins.normalize()
+	status := http.StatusOK
+	if r.putServer(ins) {
+		status = http.StatusCreated
+	}
+	r.replicate(req, &record{Op: opPut, Namespace: ins.Namespace, Addr: ins.Addr, Instance: ins})
+	writeJSON(w, status, ins)
+}
+
+// services 汇总namespace下存活实例上的所有服务名
+func (r *Registry) services(namespace string) []*serviceSummary {
+	count := make(map[string]int)
+	for _, ins := range r.aliveServers(namespace, "") {
+		for _, name := range ins.Services {
+			count[name]++
+		}
+	}
+	services := make([]*serviceSummary, 0, len(count))
+	for name, n := range count {
+		services = append(services, &serviceSummary{Name: name, Instances: n})
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services
+}
@@ -0,0 +1,77 @@
+package registry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistryAPI(t *testing.T) {
+	r := NewRegistry(time.Minute)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	if w := do("POST", "/v1/instances", `{"addr":"127.0.0.1:8001","services":["FDD","Cache"],"weight":2}`); w.Code != http.StatusCreated {
+		t.Fatalf("expect 201, got %d: %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/v1/instances", `{"addr":"127.0.0.1:8001","services":["FDD","Cache"],"weight":2}`); w.Code != http.StatusOK {
+		t.Fatalf("expect 200 on renew, got %d", w.Code)
+	}
+	if w := do("POST", "/v1/instances", `{"addr":"127.0.0.1:8002","services":["FDD"]}`); w.Code != http.StatusCreated {
+		t.Fatalf("expect 201, got %d", w.Code)
+	}
+
+	var services []*serviceSummary
+	_ = json.NewDecoder(do("GET", "/v1/services", "").Body).Decode(&services)
+	if len(services) != 2 || services[0].Name != "Cache" || services[1].Instances != 2 {
+		t.Fatalf("unexpected services: %+v", services)
+	}
+
+	var instances []*Instance
+	_ = json.NewDecoder(do("GET", "/v1/services/Cache/instances", "").Body).Decode(&instances)
+	if len(instances) != 1 || instances[0].Addr != "127.0.0.1:8001" || instances[0].Weight != 2 {
+		t.Fatalf("unexpected instances: %+v", instances)
+	}
+
+	ins := new(Instance)
+	w := do("GET", "/v1/instances/127.0.0.1:8002", "")
+	if err := json.NewDecoder(w.Body).Decode(ins); err != nil || w.Code != http.StatusOK || ins.Weight != defaultWeight {
+		t.Fatalf("unexpected instance: %d %+v", w.Code, ins)
+	}
+
+	if w := do("DELETE", "/v1/instances/127.0.0.1:8002", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expect 204, got %d", w.Code)
+	}
+	apiErr := new(apiError)
+	w = do("GET", "/v1/instances/127.0.0.1:8002", "")
+	if _ = json.NewDecoder(w.Body).Decode(apiErr); w.Code != http.StatusNotFound || apiErr.Error == "" {
+		t.Fatalf("expect 404 with error body, got %d %+v", w.Code, apiErr)
+	}
+	if w := do("DELETE", "/v1/instances/127.0.0.1:8002", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expect 404, got %d", w.Code)
+	}
+	if w := do("POST", "/v1/instances", `{"services":["FDD"]}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expect 400 without addr, got %d", w.Code)
+	}
+	if w := do("POST", "/v1/instances", `not json`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expect 400 on bad json, got %d", w.Code)
+	}
+	if w := do("PUT", "/v1/services", ""); w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") == "" {
+		t.Fatalf("expect 405, got %d", w.Code)
+	}
+	if w := do("GET", "/v1/unknown", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expect 404, got %d", w.Code)
+	}
+
+	//旧的头部协议依旧可用
+	w = do("GET", "/", "")
+	if w.Header().Get("Rpc") != "127.0.0.1:8001" {
+		t.Fatalf("unexpected legacy response: %q", w.Header().Get("Rpc"))
+	}
+}
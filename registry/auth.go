@@ -0,0 +1,249 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 注册中心的认证：
+// 写请求（登记、续约、注销）必须在Rpc-Token头中带上写令牌，或者用共享密钥对请求做HMAC签名；
+// 签名覆盖方法、URI、时间戳、nonce、实例相关的头部和请求体，时间戳与注册中心时间的偏差不能超过MaxSkew，
+// 同一个nonce在MaxSkew内只能使用一次，防止签名请求被截获后重放。
+// 配置了ReadTokens时，读请求还需要带上读令牌或写令牌。被拒绝的请求都会记录审计日志。
+const (
+	tokenHeader     = "Rpc-Token"
+	signatureHeader = "Rpc-Signature"
+	timestampHeader = "Rpc-Timestamp"
+	nonceHeader     = "Rpc-Nonce"
+	defaultMaxSkew  = 5 * time.Minute
+	maxSignedBody   = 1 << 20
+)
+
+// signedHeaders 参与签名的头部，包含旧协议中描述实例的所有头部，防止签名被挪用到其他实例上
+var signedHeaders = []string{nonceHeader, "Rpc", namespaceHeader, "Rpc-Services", "Rpc-Weight", "Rpc-Version", "Rpc-Zone", "Rpc-Tags", "Rpc-Codecs"}
+
+// AuthOption 注册中心的认证配置
+type AuthOption struct {
+	Secret      string        //签名写请求的HMAC密钥
+	WriteTokens []string      //可以直接放在Rpc-Token头中的写令牌，管理后台也用它登录
+	ReadTokens  []string      //读取需要的令牌，为空时读取不需要认证
+	MaxSkew     time.Duration //签名时间戳允许的偏差，默认5分钟
+}
+
+// Credentials 服务器心跳、注销以及注册中心之间复制时使用的凭证
+// 设置了Secret时对请求签名，设置了Token时放在Rpc-Token头中
+type Credentials struct {
+	Secret string
+	Token  string
+}
+
+// SetAuth 开启认证，opt为nil时关闭认证
+func (r *Registry) SetAuth(opt *AuthOption) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.auth = opt
+}
+
+// credentials 注册中心转发给peer时使用的凭证
+func (r *Registry) credentials() *Credentials {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.auth == nil {
+		return nil
+	}
+	creds := &Credentials{Secret: r.auth.Secret}
+	if len(r.auth.WriteTokens) > 0 {
+		creds.Token = r.auth.WriteTokens[0]
+	}
+	return creds
+}
+
+// authorize 检查请求是否带有合法的凭证，write表示请求需要写权限
+func (r *Registry) authorize(req *http.Request, write bool) error {
+	r.mu.Lock()
+	auth := r.auth
+	r.mu.Unlock()
+	if auth == nil {
+		return nil
+	}
+	if write && auth.Secret == "" && len(auth.WriteTokens) == 0 {
+		return nil
+	}
+	if !write && len(auth.ReadTokens) == 0 {
+		return nil
+	}
+	if token := req.Header.Get(tokenHeader); token != "" {
+		if containsToken(auth.WriteTokens, token) || (!write && containsToken(auth.ReadTokens, token)) {
+			return nil
+		}
+		if req.Header.Get(signatureHeader) == "" {
+			return errors.New("invalid token")
+		}
+	}
+	if req.Header.Get(signatureHeader) != "" {
+		return auth.verify(req, &r.nonces)
+	}
+	return errors.New("missing credentials")
+}
+
+// peerKey 请求已经作为peer的复制请求通过认证，见markPeer
+type peerKey struct{}
+
+// markPeer 在ServeHTTP中调用，取代普通的authorize：带有写凭证的peer复制请求被标记在context中，
+// 之后readNamespace直接读取标记，签名只校验一次，避免nonce被当作重放
+func (r *Registry) markPeer(req *http.Request) (*http.Request, bool) {
+	if !r.fromPeer(req) {
+		return req, false
+	}
+	return req.WithContext(context.WithValue(req.Context(), peerKey{}, true)), true
+}
+
+func isPeer(req *http.Request) bool {
+	peer, _ := req.Context().Value(peerKey{}).(bool)
+	return peer
+}
+
+// fromPeer 判断请求是否为带有写凭证的peer发来的复制请求，未开启认证或者没有配置写凭证时一律不算
+// 签名校验会记录nonce，每个请求只能调用一次
+func (r *Registry) fromPeer(req *http.Request) bool {
+	if req.Header.Get(replicaHeader) == "" {
+		return false
+	}
+	r.mu.Lock()
+	auth := r.auth
+	r.mu.Unlock()
+	if auth == nil || (auth.Secret == "" && len(auth.WriteTokens) == 0) {
+		return false
+	}
+	return r.authorize(req, true) == nil
+}
+
+func (auth *AuthOption) verify(req *http.Request, nonces *nonceCache) error {
+	if auth.Secret == "" {
+		return errors.New("signature not accepted")
+	}
+	timestamp := req.Header.Get(timestampHeader)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	skew := auth.MaxSkew
+	if skew <= 0 {
+		skew = defaultMaxSkew
+	}
+	if d := time.Since(time.Unix(unix, 0)); d > skew || d < -skew {
+		return fmt.Errorf("timestamp skew %v exceeds %v", d.Truncate(time.Second), skew)
+	}
+	var body []byte
+	if req.Body != nil {
+		if body, err = io.ReadAll(io.LimitReader(req.Body, maxSignedBody)); err != nil {
+			return err
+		}
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	expected := signature(auth.Secret, req.Method, req.URL.RequestURI(), timestamp, req.Header, body)
+	if !hmac.Equal([]byte(expected), []byte(req.Header.Get(signatureHeader))) {
+		return errors.New("signature mismatch")
+	}
+	nonce := req.Header.Get(nonceHeader)
+	if nonce == "" {
+		return errors.New("missing nonce")
+	}
+	//时间戳超出偏差后请求本身就会被拒绝，nonce只需要记到那时
+	if !nonces.add(nonce, time.Unix(unix, 0).Add(skew)) {
+		return errors.New("replayed nonce")
+	}
+	return nil
+}
+
+// nonceCache 记录签名请求中出现过的nonce及其过期时间
+type nonceCache struct {
+	mu     sync.Mutex
+	seen   map[string]time.Time
+	pruned time.Time
+}
+
+// add 记录nonce，nonce未过期且已经出现过时返回false
+func (c *nonceCache) add(nonce string, expire time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if c.seen == nil {
+		c.seen = make(map[string]time.Time)
+	}
+	if now.Sub(c.pruned) > time.Second {
+		for n, t := range c.seen {
+			if now.After(t) {
+				delete(c.seen, n)
+			}
+		}
+		c.pruned = now
+	}
+	if t, ok := c.seen[nonce]; ok && !now.After(t) {
+		return false
+	}
+	c.seen[nonce] = expire
+	return true
+}
+
+// reject 拒绝未认证的请求并记录审计日志
+func (r *Registry) reject(w http.ResponseWriter, req *http.Request, err error) {
+	log.Printf("rpc registry: audit: rejected %s %s from %s addr=%q namespace=%q: %v",
+		req.Method, req.URL.RequestURI(), req.RemoteAddr, req.Header.Get("Rpc"), req.Header.Get(namespaceHeader), err)
+	writeError(w, http.StatusUnauthorized, "unauthorized: "+err.Error())
+}
+
+func containsToken(tokens []string, token string) bool {
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func signature(secret, method, uri, timestamp string, h http.Header, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "%s\n%s\n%s\n", method, uri, timestamp)
+	for _, key := range signedHeaders {
+		_, _ = fmt.Fprintf(mac, "%s\n", h.Get(key))
+	}
+	_, _ = fmt.Fprintf(mac, "%x", sha256.Sum256(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// apply 在请求的头部都设置好之后调用，body为请求体
+func (c *Credentials) apply(req *http.Request, body []byte) {
+	if c == nil {
+		return
+	}
+	if c.Token != "" {
+		req.Header.Set(tokenHeader, c.Token)
+	}
+	if c.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(timestampHeader, timestamp)
+		req.Header.Set(nonceHeader, newNonce())
+		req.Header.Set(signatureHeader, signature(c.Secret, req.Method, req.URL.RequestURI(), timestamp, req.Header, body))
+	}
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
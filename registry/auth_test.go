@@ -0,0 +1,161 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"simplerpc/discovery"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRegistryAuth(t *testing.T) {
+	auth := &AuthOption{Secret: "s3cret", WriteTokens: []string{"write"}, ReadTokens: []string{"read"}}
+	r := NewRegistry(time.Minute)
+	r.SetAuth(auth)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	ins := &Instance{Addr: "127.0.0.1:8001", Services: []string{"FDD"}, Weight: defaultWeight}
+	client := &http.Client{Timeout: time.Second}
+	if err := sendHeart(client, ts.URL, ins, nil); err == nil {
+		t.Fatal("expect unsigned heartbeat rejected")
+	}
+	if err := sendHeart(client, ts.URL, ins, &Credentials{Secret: "wrong"}); err == nil {
+		t.Fatal("expect heartbeat signed with wrong secret rejected")
+	}
+	if _, ok := r.getServer(DefaultNamespace, ins.Addr); ok {
+		t.Fatal("expect rejected heartbeat not registered")
+	}
+	if err := sendHeart(client, ts.URL, ins, &Credentials{Secret: "s3cret"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sendHeart(client, ts.URL, &Instance{Addr: "127.0.0.1:8002"}, &Credentials{Token: "write"}); err != nil {
+		t.Fatal(err)
+	}
+
+	//签名后篡改地址或时间戳过旧都会被拒绝
+	do := func(modify func(req *http.Request)) int {
+		req, _ := http.NewRequest("POST", ts.URL, nil)
+		ins.setHeader(req.Header)
+		(&Credentials{Secret: "s3cret"}).apply(req, nil)
+		modify(req)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := do(func(req *http.Request) { req.Header.Set("Rpc", "10.0.0.1:8001") }); code != http.StatusUnauthorized {
+		t.Fatalf("expect tampered address rejected, got %d", code)
+	}
+	//同一个签名请求不能重放
+	req, _ := http.NewRequest("POST", ts.URL, nil)
+	ins.setHeader(req.Header)
+	(&Credentials{Secret: "s3cret"}).apply(req, nil)
+	for i, expect := range []int{http.StatusOK, http.StatusUnauthorized} {
+		resp, err := client.Do(req.Clone(req.Context()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != expect {
+			t.Fatalf("expect %d on attempt %d, got %d", expect, i, resp.StatusCode)
+		}
+	}
+	if code := do(func(req *http.Request) { req.Header.Del(nonceHeader) }); code != http.StatusUnauthorized {
+		t.Fatalf("expect signature without nonce rejected, got %d", code)
+	}
+	if code := do(func(req *http.Request) {
+		timestamp := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+		req.Header.Set(timestampHeader, timestamp)
+		req.Header.Set(signatureHeader, signature("s3cret", req.Method, req.URL.RequestURI(), timestamp, req.Header, nil))
+	}); code != http.StatusUnauthorized {
+		t.Fatalf("expect stale timestamp rejected, got %d", code)
+	}
+
+	//读取需要读令牌
+	if _, err := discovery.NewServerDiscovery(ts.URL, 0).GetAll(); err == nil {
+		t.Fatal("expect discovery without token rejected")
+	}
+	d := discovery.NewServerDiscovery(ts.URL, 0, &discovery.Option{Token: "read"})
+	if servers, err := d.GetAll(); err != nil || len(servers) != 2 {
+		t.Fatalf("expect 2 servers with read token, got %v %v", servers, err)
+	}
+	if err := sendHeart(client, ts.URL, ins, &Credentials{Token: "read"}); err == nil {
+		t.Fatal("expect read token not allowed to write")
+	}
+
+	//读令牌不能冒充peer读取所有命名空间
+	req, _ = http.NewRequest("GET", ts.URL+"/v1/instances?namespace=*", nil)
+	req.Header.Set(replicaHeader, "true")
+	req.Header.Set(tokenHeader, "read")
+	if resp, err := client.Do(req); err != nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expect replica read with read token forbidden, got %v %v", resp, err)
+	}
+
+	//管理后台用写令牌作为Basic认证的密码
+	req, _ = http.NewRequest("GET", ts.URL+"/admin", nil)
+	if resp, err := client.Do(req); err != nil || resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+		t.Fatalf("expect admin to require auth, got %v %v", resp, err)
+	}
+	req.SetBasicAuth("ops", "write")
+	if resp, err := client.Do(req); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("expect admin with write token, got %v %v", resp, err)
+	}
+
+	if err := Deregister(ts.URL, ins.Addr); err == nil {
+		t.Fatal("expect unsigned deregister rejected")
+	}
+	if err := Deregister(ts.URL, ins.Addr, &Credentials{Secret: "s3cret"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.getServer(DefaultNamespace, ins.Addr); ok {
+		t.Fatal("expect instance deregistered")
+	}
+}
+
+func TestRegistryAuthReplication(t *testing.T) {
+	auth := &AuthOption{Secret: "s3cret"}
+	nodes := make([]*Registry, 2)
+	servers := make([]*httptest.Server, 2)
+	for i := range nodes {
+		nodes[i] = NewRegistry(time.Minute)
+		nodes[i].SetAuth(auth)
+		servers[i] = httptest.NewServer(nodes[i])
+		defer servers[i].Close()
+		defer nodes[i].Close()
+	}
+	nodes[0].SetPeers(servers[1].URL)
+	nodes[1].SetPeers(servers[0].URL)
+
+	ins := &Instance{Addr: "127.0.0.1:8001", Namespace: "prod", Weight: defaultWeight}
+	if err := sendHeart(http.DefaultClient, servers[0].URL, ins, &Credentials{Secret: "s3cret"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := nodes[1].getServer("prod", ins.Addr); !ok {
+		t.Fatal("expect signed replication accepted by peer")
+	}
+}
+
+func TestRegistryAuthPeerSync(t *testing.T) {
+	//只配置密钥和读令牌时，peer的签名既用于读取认证也用于读取所有命名空间，只能校验一次
+	auth := &AuthOption{Secret: "s3cret", ReadTokens: []string{"read"}}
+	node := NewRegistry(time.Minute)
+	node.SetAuth(auth)
+	defer node.Close()
+	ts := httptest.NewServer(node)
+	defer ts.Close()
+	node.putServer(&Instance{Addr: "127.0.0.1:8001", Namespace: "prod", Weight: defaultWeight})
+
+	joined := NewRegistry(time.Minute)
+	joined.SetAuth(auth)
+	defer joined.Close()
+	joined.SetPeers(ts.URL)
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := joined.getServer("prod", "127.0.0.1:8001"); !ok {
+		t.Fatal("expect joined node synced from peer with signed request")
+	}
+}
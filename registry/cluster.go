@@ -0,0 +1,184 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 多个注册中心节点组成集群，互相复制登记、注销以及管理后台的剔除和摘除流量：
+// 节点收到来自服务器的写请求后异步转发给所有peer，转发的请求带上replicaHeader，peer不再继续转发；
+// 心跳续约同样会被转发，否则其他节点上的实例会因为收不到心跳而过期。
+// 节点启动或加入集群时从peer拉取一次全量实例，读取所有命名空间需要peer开启认证并且本节点带有写凭证，
+// 未开启认证时只能等待心跳续约把实例复制过来。
+// 队列满被丢弃或者转发失败的记录不单独重试，而是把peer标记为落后，由后台定期把全量实例推送给它；
+// 丢失的注销同样不会重放，peer上的实例收不到心跳后自然过期。
+const (
+	replicaHeader    = "Rpc-Replica"
+	replicaQueueSize = 1024
+	replicaTimeout   = 3 * time.Second
+)
+
+// replicaResync 检查peer是否落后并推送全量实例的间隔
+var replicaResync = 10 * time.Second
+
+type peer struct {
+	url      string
+	queue    chan *record
+	registry *Registry
+	behind   int32 //有记录没有送达，需要全量推送
+}
+
+// SetPeers 设置集群中的其他注册中心节点，peers为它们的地址，如http://127.0.0.1:9091
+func (r *Registry) SetPeers(peers ...string) {
+	r.mu.Lock()
+	if r.stopPeers != nil {
+		close(r.stopPeers)
+	}
+	r.stopPeers = make(chan struct{})
+	r.peers = make([]*peer, 0, len(peers))
+	for _, u := range peers {
+		p := &peer{url: strings.TrimRight(u, "/"), queue: make(chan *record, replicaQueueSize), registry: r}
+		r.peers = append(r.peers, p)
+		go p.run(r.stopPeers, replicaResync)
+	}
+	list := r.peers
+	r.mu.Unlock()
+
+	for _, p := range list {
+		go r.syncFrom(p)
+	}
+}
+
+// replicate 将来自服务器的写请求转发给所有peer，来自peer的复制请求不再转发
+func (r *Registry) replicate(req *http.Request, rec *record) {
+	if req.Header.Get(replicaHeader) != "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.peers {
+		select {
+		case p.queue <- rec:
+		default:
+			atomic.StoreInt32(&p.behind, 1)
+			log.Printf("rpc registry: replica queue to %s is full, drop %s %s and resync later", p.url, rec.Op, rec.Addr)
+		}
+	}
+}
+
+func (p *peer) run(stop chan struct{}, resync time.Duration) {
+	client := &http.Client{Timeout: replicaTimeout}
+	t := time.NewTicker(resync)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case rec := <-p.queue:
+			if err := p.send(client, rec, p.registry.credentials()); err != nil {
+				atomic.StoreInt32(&p.behind, 1)
+				log.Printf("rpc registry: replicate %s to %s error: %v", rec.Op, p.url, err)
+			}
+		case <-t.C:
+			if atomic.CompareAndSwapInt32(&p.behind, 1, 0) {
+				p.resync(client)
+			}
+		}
+	}
+}
+
+// resync 把本节点的全量实例及其摘除状态推送给peer，推送失败时保持落后标记等待下一次重试
+func (p *peer) resync(client *http.Client) {
+	p.registry.mu.Lock()
+	records := make([]*record, 0, len(p.registry.kv))
+	for _, item := range p.registry.kv {
+		ins := item.Instance
+		records = append(records, &record{Op: opPut, Namespace: ins.Namespace, Addr: ins.Addr, Instance: &ins})
+		if item.draining {
+			records = append(records, drainRecord(ins.Namespace, ins.Addr, true))
+		}
+	}
+	p.registry.mu.Unlock()
+
+	creds := p.registry.credentials()
+	for _, rec := range records {
+		if err := p.send(client, rec, creds); err != nil {
+			atomic.StoreInt32(&p.behind, 1)
+			log.Printf("rpc registry: resync to %s error: %v", p.url, err)
+			return
+		}
+	}
+	log.Printf("rpc registry: resynced %d records to peer %s", len(records), p.url)
+}
+
+func (p *peer) send(client *http.Client, rec *record, creds *Credentials) error {
+	var req *http.Request
+	var data []byte
+	var err error
+	switch rec.Op {
+	case opPut:
+		data, _ = json.Marshal(rec.Instance)
+		req, err = http.NewRequest(http.MethodPost, p.url+apiPrefix+"instances", bytes.NewReader(data))
+	case opDelete:
+		target := p.url + apiPrefix + "instances/" + url.PathEscape(rec.Addr) + "?namespace=" + url.QueryEscape(namespaceOf(rec.Namespace))
+		req, err = http.NewRequest(http.MethodDelete, target, nil)
+	case opDrain, opUndrain:
+		target := p.url + adminPrefix + "/instances/" + url.PathEscape(rec.Addr) + "/" + rec.Op + "?namespace=" + url.QueryEscape(namespaceOf(rec.Namespace))
+		req, err = http.NewRequest(http.MethodPost, target, nil)
+	default:
+		return fmt.Errorf("unknown op %s", rec.Op)
+	}
+	if err != nil {
+		return err
+	}
+	req.Header.Set(replicaHeader, "true")
+	creds.apply(req, data)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("peer responded %s", resp.Status)
+	}
+	return nil
+}
+
+// syncFrom 从peer拉取全量实例，用于节点启动或重新加入集群
+func (r *Registry) syncFrom(p *peer) {
+	client := &http.Client{Timeout: replicaTimeout}
+	req, err := http.NewRequest(http.MethodGet, p.url+apiPrefix+"instances?namespace="+url.QueryEscape(AllNamespaces), nil)
+	if err != nil {
+		log.Printf("rpc registry: sync from peer %s error: %v", p.url, err)
+		return
+	}
+	req.Header.Set(replicaHeader, "true")
+	r.credentials().apply(req, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("rpc registry: sync from peer %s error: %v", p.url, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("rpc registry: sync from peer %s error: peer responded %s", p.url, resp.Status)
+		return
+	}
+	var instances []*Instance
+	if err := json.NewDecoder(resp.Body).Decode(&instances); err != nil {
+		log.Printf("rpc registry: sync from peer %s error: %v", p.url, err)
+		return
+	}
+	for _, ins := range instances {
+		ins.normalize()
+		r.putServer(ins)
+	}
+	log.Printf("rpc registry: synced %d instances from peer %s", len(instances), p.url)
+}
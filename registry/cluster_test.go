@@ -0,0 +1,130 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"simplerpc/discovery"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRegistryCluster(t *testing.T) {
+	nodes := make([]*Registry, 3)
+	servers := make([]*httptest.Server, 3)
+	for i := range nodes {
+		nodes[i] = NewRegistry(time.Minute)
+		servers[i] = httptest.NewServer(nodes[i])
+		defer servers[i].Close()
+		defer nodes[i].Close()
+	}
+	for i, node := range nodes {
+		peers := make([]string, 0)
+		for j, s := range servers {
+			if j != i {
+				peers = append(peers, s.URL)
+			}
+		}
+		node.SetPeers(peers...)
+	}
+
+	addr := "127.0.0.1:9997"
+	h := StartHeartbeat(servers[0].URL, &Instance{Addr: addr, Services: []string{"FDD"}, Weight: defaultWeight}, 100*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	for i, node := range nodes {
+		if _, ok := node.getServer(DefaultNamespace, addr); !ok {
+			t.Fatalf("expect instance replicated to node %d", i)
+		}
+	}
+
+	//管理后台的摘除流量同样复制到其他节点
+	resp, err := http.Post(servers[0].URL+adminPrefix+"/instances/"+addr+"/drain", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	time.Sleep(100 * time.Millisecond)
+	for i, node := range nodes {
+		if servers := node.aliveServers(DefaultNamespace, "FDD"); len(servers) != 0 {
+			t.Fatalf("expect instance drained on node %d, got %v", i, servers)
+		}
+	}
+	resp, err = http.Post(servers[0].URL+adminPrefix+"/instances/"+addr+"/undrain", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	//discovery在节点不可用时切换到下一个节点
+	d := discovery.NewServerDiscovery(servers[1].URL+","+servers[2].URL, 0)
+	if got, err := d.Get(); err != nil || got != addr {
+		t.Fatalf("expect %s from node 1, got %s %v", addr, got, err)
+	}
+	servers[1].Close()
+	if got, err := d.Get(); err != nil || got != addr {
+		t.Fatalf("expect failover to node 2, got %s %v", got, err)
+	}
+
+	//在任意节点注销，其他节点同步删除
+	h.Stop()
+	if err := Deregister(servers[2].URL, addr); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := nodes[0].getServer(DefaultNamespace, addr); ok {
+		t.Fatal("expect deregistration replicated to node 0")
+	}
+
+	//新加入的节点凭写凭证从peer拉取全量实例
+	auth := &AuthOption{Secret: "s3cret"}
+	nodes[0].SetAuth(auth)
+	nodes[0].putServer(&Instance{Addr: "127.0.0.1:9996", Weight: defaultWeight})
+	joined := NewRegistry(time.Minute)
+	defer joined.Close()
+	joined.SetAuth(auth)
+	joined.SetPeers(servers[0].URL)
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := joined.getServer(DefaultNamespace, "127.0.0.1:9996"); !ok {
+		t.Fatal("expect joined node synced from peer")
+	}
+}
+
+func TestRegistryClusterResync(t *testing.T) {
+	resync := replicaResync
+	replicaResync = 50 * time.Millisecond
+	defer func() { replicaResync = resync }()
+
+	//peer暂时不可用，转发失败的登记在其恢复后由全量推送补上
+	var down int32 = 1
+	peerNode := NewRegistry(time.Minute)
+	defer peerNode.Close()
+	peerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.LoadInt32(&down) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		peerNode.ServeHTTP(w, req)
+	}))
+	defer peerServer.Close()
+
+	node := NewRegistry(time.Minute)
+	defer node.Close()
+	node.SetPeers(peerServer.URL)
+	ts := httptest.NewServer(node)
+	defer ts.Close()
+
+	addr := "127.0.0.1:9995"
+	if err := sendHeart(http.DefaultClient, ts.URL, &Instance{Addr: addr, Weight: defaultWeight}, nil); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := peerNode.getServer(DefaultNamespace, addr); ok {
+		t.Fatal("expect replication to fail while peer is down")
+	}
+	atomic.StoreInt32(&down, 0)
+	time.Sleep(200 * time.Millisecond)
+	if _, ok := peerNode.getServer(DefaultNamespace, addr); !ok {
+		t.Fatal("expect instance resynced after peer recovered")
+	}
+}
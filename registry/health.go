@@ -0,0 +1,191 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"simplerpc/client"
+	"simplerpc/server"
+	"sync"
+	"time"
+)
+
+// 主动健康检查：心跳只能说明服务器进程的心跳goroutine还在运行，
+// 注册中心定期调用每个实例上的Health.Check，连续失败FailureThreshold次的实例不再返回给discovery，
+// 探测恢复成功后重新加入。实例是否健康不影响心跳续约，也不会被复制到其他节点，每个节点各自探测
+type HealthCheckOption struct {
+	Interval         time.Duration //探测间隔，默认10秒
+	Timeout          time.Duration //单次探测的超时时间，包括建立连接，默认2秒
+	FailureThreshold int           //连续失败多少次后摘除，默认3次
+}
+
+const (
+	defaultCheckInterval    = 10 * time.Second
+	defaultCheckTimeout     = 2 * time.Second
+	defaultFailureThreshold = 3
+)
+
+var errProbeTimeout = errors.New("health check timeout")
+
+// EnableHealthCheck 开启主动健康检查，opt为nil时使用默认配置；重复调用会以新的配置重新开始
+func (r *Registry) EnableHealthCheck(opt *HealthCheckOption) {
+	o := HealthCheckOption{}
+	if opt != nil {
+		o = *opt
+	}
+	if o.Interval <= 0 {
+		o.Interval = defaultCheckInterval
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultCheckTimeout
+	}
+	if o.FailureThreshold <= 0 {
+		o.FailureThreshold = defaultFailureThreshold
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopCheck != nil {
+		close(r.stopCheck)
+	}
+	r.stopCheck = make(chan struct{})
+	go r.checkLoop(&o, r.stopCheck)
+}
+
+// DisableHealthCheck 停止主动健康检查，被摘除的实例重新加入
+func (r *Registry) DisableHealthCheck() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopHealthCheck()
+}
+
+// stopHealthCheck 在持有r.mu时调用
+func (r *Registry) stopHealthCheck() {
+	if r.stopCheck == nil {
+		return
+	}
+	close(r.stopCheck)
+	r.stopCheck = nil
+	changed := false
+	for _, item := range r.kv {
+		changed = changed || item.unhealthy
+		item.unhealthy, item.failures = false, 0
+	}
+	if changed {
+		r.notify()
+	}
+}
+
+func (r *Registry) checkLoop(opt *HealthCheckOption, stop chan struct{}) {
+	t := time.NewTicker(opt.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			r.checkAll(opt, stop)
+		}
+	}
+}
+
+// checkAll 并发探测所有实例，再统一更新实例的健康状态
+func (r *Registry) checkAll(opt *HealthCheckOption, stop chan struct{}) {
+	r.mu.Lock()
+	r.expire()
+	addrs := make(map[string]string, len(r.kv))
+	for key, item := range r.kv {
+		addrs[key] = item.Addr
+	}
+	r.mu.Unlock()
+
+	results := make(map[string]error, len(addrs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for key, addr := range addrs {
+		wg.Add(1)
+		go func(key, addr string) {
+			defer wg.Done()
+			err := probe(addr, opt.Timeout)
+			mu.Lock()
+			results[key] = err
+			mu.Unlock()
+		}(key, addr)
+	}
+	wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	select {
+	case <-stop:
+		return
+	default:
+	}
+	for key, err := range results {
+		item, ok := r.kv[key]
+		if !ok {
+			continue
+		}
+		if err == nil {
+			item.failures = 0
+			if item.unhealthy {
+				item.unhealthy = false
+				log.Printf("rpc registry: instance %s recovered, back in rotation", key)
+				r.notify()
+			}
+			continue
+		}
+		item.failures++
+		if !item.unhealthy && item.failures >= opt.FailureThreshold {
+			item.unhealthy = true
+			log.Printf("rpc registry: instance %s failed %d health checks, out of rotation: %v", key, item.failures, err)
+			r.notify()
+		}
+	}
+}
+
+// probe 调用addr上的Health.Check，超时后关闭连接，避免卡死的服务器拖住探测
+func probe(addr string, timeout time.Duration) error {
+	done := make(chan error, 1)
+	var mu sync.Mutex
+	var cli *client.Client
+	timedOut := false
+	go func() {
+		c, err := client.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			done <- err
+			return
+		}
+		mu.Lock()
+		if timedOut {
+			mu.Unlock()
+			_ = c.Close()
+			return
+		}
+		cli = c
+		mu.Unlock()
+		defer func() { _ = c.Close() }()
+		reply := new(server.HealthCheckResponse)
+		if err := c.Call(server.HealthCheckMethod, &server.HealthCheckRequest{}, reply); err != nil {
+			done <- err
+			return
+		}
+		if reply.Status != server.HealthServing {
+			done <- fmt.Errorf("status %s", reply.Status)
+			return
+		}
+		done <- nil
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		mu.Lock()
+		timedOut = true
+		if cli != nil {
+			_ = cli.Close()
+		}
+		mu.Unlock()
+		return errProbeTimeout
+	}
+}
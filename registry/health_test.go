@@ -0,0 +1,56 @@
+package registry
+
+import (
+	"net"
+	"simplerpc/server"
+	"testing"
+	"time"
+)
+
+func TestActiveHealthCheck(t *testing.T) {
+	s := server.NewServer()
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
+	go s.Accept(lis)
+	defer s.Shutdown()
+
+	//只接受连接、从不响应的服务器，模拟心跳还在但已经卡死的进程
+	stuck, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer stuck.Close()
+	go func() {
+		for {
+			conn, err := stuck.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	r := NewRegistry(time.Minute)
+	defer r.Close()
+	r.putServer(&Instance{Addr: lis.Addr().String(), Services: []string{"FDD"}})
+	r.putServer(&Instance{Addr: stuck.Addr().String(), Services: []string{"FDD"}})
+	r.EnableHealthCheck(&HealthCheckOption{Interval: 50 * time.Millisecond, Timeout: 100 * time.Millisecond, FailureThreshold: 2})
+
+	time.Sleep(500 * time.Millisecond)
+	if servers := r.aliveServers(DefaultNamespace, "FDD"); len(servers) != 1 || servers[0].Addr != lis.Addr().String() {
+		t.Fatalf("expect stuck instance out of rotation, got %v", servers)
+	}
+
+	s.SetServingStatus("", server.HealthNotServing)
+	time.Sleep(500 * time.Millisecond)
+	if servers := r.aliveServers(DefaultNamespace, "FDD"); len(servers) != 0 {
+		t.Fatalf("expect NOT_SERVING instance out of rotation, got %v", servers)
+	}
+
+	s.SetServingStatus("", server.HealthServing)
+	time.Sleep(500 * time.Millisecond)
+	if servers := r.aliveServers(DefaultNamespace, "FDD"); len(servers) != 1 {
+		t.Fatalf("expect recovered instance back in rotation, got %v", servers)
+	}
+
+	r.DisableHealthCheck()
+	if servers := r.aliveServers(DefaultNamespace, "FDD"); len(servers) != 2 {
+		t.Fatalf("expect all instances back after disabling checks, got %v", servers)
+	}
+}
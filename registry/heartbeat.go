@@ -0,0 +1,255 @@
+package registry
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	minRetryInterval  = 200 * time.Millisecond
+	deregisterTimeout = 5 * time.Second
+)
+
+// Heartbeater 在后台定期向注册中心发送心跳
+// 注册中心不可达时按指数退避重试并记录日志，不会让服务器退出；
+// 心跳是幂等的登记请求，注册中心重启丢失状态后下一次心跳即可重新登记
+type Heartbeater struct {
+	registry   string
+	registries []string //registry可以是以逗号分隔的多个注册中心节点，失败时切换到下一个
+	current    int
+	ins        *Instance
+	interval   time.Duration
+	client     *http.Client
+	creds      *Credentials
+
+	mu       sync.Mutex
+	lastBeat time.Time //最近一次成功的时间
+	lastErr  error
+	failures int //连续失败次数
+	stopped  bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// HeartbeatStatus 心跳的健康状况
+type HeartbeatStatus struct {
+	Healthy  bool
+	LastBeat time.Time
+	LastErr  error
+	Failures int
+}
+
+// StartHeartbeat 启动后台心跳并立即返回，interval为0时使用比注册中心超时略短的默认间隔
+// 注册中心开启了认证时通过creds提供凭证
+func StartHeartbeat(registry string, ins *Instance, interval time.Duration, creds ...*Credentials) *Heartbeater {
+	if interval == 0 {
+		interval = defaultTimeout - time.Duration(1)*time.Second
+	}
+	h := &Heartbeater{
+		registry:   registry,
+		registries: []string{registry},
+		ins:        ins,
+		interval:   interval,
+		client:     &http.Client{Timeout: interval},
+		creds:      firstCredentials(creds),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if registries := splitList(registry); len(registries) > 0 {
+		h.registries = registries
+	}
+	ins.normalize()
+	if old := putBeat(registry, ins.key(), h); old != nil {
+		old.Stop()
+	}
+	go h.run()
+	return h
+}
+
+// Heartbeat 定期向注册中心登记addr以及其上提供的服务，阻塞直到心跳被停止
+func Heartbeat(registry, addr string, duration time.Duration, services ...string) {
+	HeartbeatInstance(registry, &Instance{Addr: addr, Services: services, Weight: defaultWeight}, duration)
+}
+
+// HeartbeatInstance 定期向注册中心登记实例及其元数据，阻塞直到心跳被停止
+func HeartbeatInstance(registry string, ins *Instance, duration time.Duration, creds ...*Credentials) {
+	h := StartHeartbeat(registry, ins, duration, creds...)
+	<-h.done
+}
+
+func (h *Heartbeater) run() {
+	defer close(h.done)
+	defer removeBeat(h.registry, h.ins.key(), h)
+	for {
+		wait := h.interval
+		if err := h.beat(); err != nil {
+			wait = h.retryInterval()
+			log.Printf("rpc registry: heartbeat to %s failed, retry in %v: %v", h.registry, wait, err)
+		}
+		select {
+		case <-h.stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (h *Heartbeater) beat() error {
+	h.mu.Lock()
+	registry := h.registries[h.current]
+	h.mu.Unlock()
+	err := sendHeart(h.client, registry, h.ins, h.creds)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastErr = err
+	if err != nil {
+		h.failures++
+		h.current = (h.current + 1) % len(h.registries)
+		return err
+	}
+	if h.failures > 0 {
+		log.Printf("rpc registry: heartbeat to %s recovered after %d failures", h.registry, h.failures)
+	}
+	h.failures = 0
+	h.lastBeat = time.Now()
+	return nil
+}
+
+// retryInterval 失败后从minRetryInterval开始指数退避，最长不超过正常的心跳间隔
+func (h *Heartbeater) retryInterval() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	wait := minRetryInterval
+	for i := 1; i < h.failures && wait < h.interval; i++ {
+		wait *= 2
+	}
+	if wait > h.interval {
+		wait = h.interval
+	}
+	return wait
+}
+
+// Stop 停止心跳并等待后台goroutine退出，可以重复调用
+func (h *Heartbeater) Stop() {
+	h.mu.Lock()
+	if !h.stopped {
+		h.stopped = true
+		close(h.stop)
+	}
+	h.mu.Unlock()
+	<-h.done
+}
+
+func (h *Heartbeater) Status() HeartbeatStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return HeartbeatStatus{
+		Healthy:  !h.stopped && h.failures == 0 && !h.lastBeat.IsZero(),
+		LastBeat: h.lastBeat,
+		LastErr:  h.lastErr,
+		Failures: h.failures,
+	}
+}
+
+func (h *Heartbeater) Healthy() bool {
+	return h.Status().Healthy
+}
+
+func sendHeart(client *http.Client, registry string, ins *Instance, creds *Credentials) error {
+	log.Println(ins.Addr, "send heart beat to registry", registry)
+	req, err := http.NewRequest("POST", registry, nil)
+	if err != nil {
+		return err
+	}
+	ins.setHeader(req.Header)
+	creds.apply(req, nil)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("rpc registry: heartbeat rejected: %s", resp.Status)
+	}
+	return nil
+}
+
+// 本进程内正在运行的心跳，Deregister时需要先停止心跳，否则实例会被重新登记
+var (
+	beatMu sync.Mutex
+	beats  = make(map[string]*Heartbeater)
+)
+
+// putBeat和removeBeat的key为实例的instanceKey
+func putBeat(registry, key string, h *Heartbeater) *Heartbeater {
+	beatMu.Lock()
+	defer beatMu.Unlock()
+	old := beats[registry+"|"+key]
+	beats[registry+"|"+key] = h
+	return old
+}
+
+func removeBeat(registry, key string, h *Heartbeater) {
+	beatMu.Lock()
+	defer beatMu.Unlock()
+	if beats[registry+"|"+key] == h {
+		delete(beats, registry+"|"+key)
+	}
+}
+
+// Deregister 停止本进程内addr到registry的心跳，并从注册中心注销addr
+// 服务器优雅关闭时调用，客户端无需等待心跳超时就不会再被路由到该实例
+func Deregister(registry, addr string, creds ...*Credentials) error {
+	return DeregisterNamespace(registry, DefaultNamespace, addr, creds...)
+}
+
+// DeregisterNamespace 与Deregister相同，注销的是namespace下的实例
+func DeregisterNamespace(registry, namespace, addr string, creds ...*Credentials) error {
+	beatMu.Lock()
+	h := beats[registry+"|"+instanceKey(namespace, addr)]
+	beatMu.Unlock()
+	if h != nil {
+		h.Stop()
+	}
+
+	//集群中的节点会互相复制注销请求，只需要一个节点成功
+	var err error
+	for _, node := range splitList(registry) {
+		if err = sendDeregister(node, namespace, addr, firstCredentials(creds)); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func sendDeregister(registry, namespace, addr string, creds *Credentials) error {
+	req, err := http.NewRequest("DELETE", registry, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Rpc", addr)
+	req.Header.Set(namespaceHeader, namespace)
+	creds.apply(req, nil)
+	//注册中心挂起时不能让服务器的关闭流程一直等待
+	client := &http.Client{Timeout: deregisterTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("rpc registry: deregister %s failed: %s", addr, resp.Status)
+	}
+	return nil
+}
+
+func firstCredentials(creds []*Credentials) *Credentials {
+	if len(creds) > 0 {
+		return creds[0]
+	}
+	return nil
+}
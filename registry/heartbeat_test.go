@@ -0,0 +1,49 @@
+package registry
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHeartbeaterRetry(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registryAddr := lis.Addr().String()
+	_ = lis.Close()
+
+	//注册中心不可达时心跳不会退出进程，而是持续重试
+	h := StartHeartbeat("http://"+registryAddr, &Instance{Addr: "127.0.0.1:9998", Weight: defaultWeight}, 100*time.Millisecond)
+	defer h.Stop()
+	time.Sleep(300 * time.Millisecond)
+	if status := h.Status(); status.Healthy || status.Failures == 0 || status.LastErr == nil {
+		t.Fatalf("expect unhealthy heartbeat, got %+v", status)
+	}
+
+	//注册中心恢复后重新登记
+	r := NewRegistry(time.Minute)
+	lis, err = net.Listen("tcp", registryAddr)
+	if err != nil {
+		t.Skipf("registry address reused: %v", err)
+	}
+	srv := &http.Server{Handler: r}
+	go srv.Serve(lis)
+	defer srv.Close()
+	time.Sleep(300 * time.Millisecond)
+	if !h.Healthy() {
+		t.Fatalf("expect heartbeat recovered, got %+v", h.Status())
+	}
+	if _, ok := r.getServer(DefaultNamespace, "127.0.0.1:9998"); !ok {
+		t.Fatal("expect instance re-registered")
+	}
+
+	h.Stop()
+	r.removeServer(DefaultNamespace, "127.0.0.1:9998")
+	time.Sleep(200 * time.Millisecond)
+	if _, ok := r.getServer(DefaultNamespace, "127.0.0.1:9998"); ok {
+		t.Fatal("expect no heartbeat after stop")
+	}
+}
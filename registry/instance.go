@@ -0,0 +1,79 @@
+package registry
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Instance 服务器实例向注册中心登记的信息
+// 负载均衡和路由可以依据这些元数据做加权轮询、版本锁定、同机房优先等
+type Instance struct {
+	Addr      string   `json:"addr"`
+	Namespace string   `json:"namespace,omitempty"` //所在的命名空间，未设置时为DefaultNamespace
+	Services  []string `json:"services,omitempty"`  //该实例上注册的服务名
+	Weight    int      `json:"weight"`              //权重，未设置时为1
+	Version   string   `json:"version,omitempty"`
+	Zone      string   `json:"zone,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	Codecs    []string `json:"codecs,omitempty"` //支持的编码方式，如application/gob
+}
+
+const defaultWeight = 1
+
+func (ins *Instance) hasService(service string) bool {
+	if service == "" {
+		return true
+	}
+	for _, name := range ins.Services {
+		if name == service {
+			return true
+		}
+	}
+	return false
+}
+
+// setHeader 将实例信息编码到心跳请求的头部
+func (ins *Instance) setHeader(h http.Header) {
+	h.Set("Rpc", ins.Addr)
+	h.Set(namespaceHeader, ins.Namespace)
+	h.Set("Rpc-Services", strings.Join(ins.Services, ","))
+	h.Set("Rpc-Weight", strconv.Itoa(ins.Weight))
+	h.Set("Rpc-Version", ins.Version)
+	h.Set("Rpc-Zone", ins.Zone)
+	h.Set("Rpc-Tags", strings.Join(ins.Tags, ","))
+	h.Set("Rpc-Codecs", strings.Join(ins.Codecs, ","))
+}
+
+// instanceFromHeader 从心跳请求的头部解析实例信息，只带Rpc头的旧版心跳同样有效
+func instanceFromHeader(h http.Header) *Instance {
+	ins := &Instance{
+		Addr:      h.Get("Rpc"),
+		Namespace: h.Get(namespaceHeader),
+		Services:  splitList(h.Get("Rpc-Services")),
+		Version:   h.Get("Rpc-Version"),
+		Zone:      h.Get("Rpc-Zone"),
+		Tags:      splitList(h.Get("Rpc-Tags")),
+		Codecs:    splitList(h.Get("Rpc-Codecs")),
+	}
+	ins.Weight, _ = strconv.Atoi(h.Get("Rpc-Weight"))
+	ins.normalize()
+	return ins
+}
+
+func (ins *Instance) normalize() {
+	if ins.Weight <= 0 {
+		ins.Weight = defaultWeight
+	}
+	ins.Namespace = namespaceOf(ins.Namespace)
+}
+
+func splitList(s string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
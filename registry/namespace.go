@@ -0,0 +1,73 @@
+package registry
+
+import (
+	"net/http"
+)
+
+// 命名空间用来隔离不同的环境或租户，如dev、staging、prod
+// 实例登记在自己的命名空间下，读取时只能拿到同一命名空间的实例；
+// 调用方在namespaceHeader中声明自己所在的命名空间，读取其他命名空间或AllNamespaces需要注册中心显式允许
+const (
+	DefaultNamespace = "default"
+	AllNamespaces    = "*"
+	namespaceHeader  = "Rpc-Namespace"
+)
+
+func namespaceOf(namespace string) string {
+	if namespace == "" {
+		return DefaultNamespace
+	}
+	return namespace
+}
+
+func instanceKey(namespace, addr string) string {
+	return namespaceOf(namespace) + "/" + addr
+}
+
+func (ins *Instance) key() string {
+	return instanceKey(ins.Namespace, ins.Addr)
+}
+
+func (ins *Instance) inNamespace(namespace string) bool {
+	return namespace == AllNamespaces || namespaceOf(ins.Namespace) == namespaceOf(namespace)
+}
+
+// AllowCrossNamespace 允许来自namespaces的调用方读取其他命名空间的实例，
+// namespaces包含AllNamespaces时允许所有调用方跨命名空间读取
+func (r *Registry) AllowCrossNamespace(namespaces ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.crossNamespace == nil {
+		r.crossNamespace = make(map[string]bool)
+	}
+	for _, namespace := range namespaces {
+		r.crossNamespace[namespaceOf(namespace)] = true
+	}
+}
+
+// canRead 判断命名空间为caller的调用方能否读取target下的实例
+// 未声明命名空间的调用方视作DefaultNamespace
+func (r *Registry) canRead(caller, target string) bool {
+	caller = namespaceOf(caller)
+	if target != AllNamespaces && caller == namespaceOf(target) {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.crossNamespace[caller] || r.crossNamespace[AllNamespaces]
+}
+
+// readNamespace 从?namespace=中取出要读取的命名空间并检查权限，没有权限时返回403
+// 来自peer的同步请求需要读取所有命名空间，只有开启认证并且带有写凭证时才不受限制
+func (r *Registry) readNamespace(w http.ResponseWriter, req *http.Request) (string, bool) {
+	target := namespaceOf(req.URL.Query().Get("namespace"))
+	if isPeer(req) {
+		return target, true
+	}
+	caller := req.Header.Get(namespaceHeader)
+	if !r.canRead(caller, target) {
+		writeError(w, http.StatusForbidden, "namespace "+caller+" is not allowed to read namespace "+target)
+		return "", false
+	}
+	return target, true
+}
@@ -0,0 +1,104 @@
+package registry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"simplerpc/discovery"
+	"testing"
+	"time"
+)
+
+func TestNamespaceIsolation(t *testing.T) {
+	r := NewRegistry(time.Minute)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	//同一个地址可以同时登记在不同的命名空间下
+	r.putServer(&Instance{Addr: "127.0.0.1:8001", Namespace: "staging", Services: []string{"FDD"}})
+	r.putServer(&Instance{Addr: "127.0.0.1:8001", Namespace: "prod", Services: []string{"FDD"}, Version: "v2"})
+	r.putServer(&Instance{Addr: "127.0.0.1:8002", Namespace: "prod", Services: []string{"FDD"}})
+	r.putServer(&Instance{Addr: "127.0.0.1:8003", Services: []string{"FDD"}})
+
+	d := discovery.NewServerDiscovery(ts.URL, 0, &discovery.Option{Namespace: "prod", Service: "FDD"})
+	instances, err := d.GetInstances()
+	if err != nil || len(instances) != 2 || instances[0].Version != "v2" || instances[1].Addr != "127.0.0.1:8002" {
+		t.Fatalf("expect only prod instances, got %v %v", instances, err)
+	}
+	d = discovery.NewServerDiscovery(ts.URL, 0)
+	if servers, err := d.GetAll(); err != nil || len(servers) != 1 || servers[0] != "127.0.0.1:8003" {
+		t.Fatalf("expect only default instances, got %v %v", servers, err)
+	}
+
+	get := func(target, caller string) int {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+target, nil)
+		if caller != "" {
+			req.Header.Set(namespaceHeader, caller)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := get("/v1/instances?namespace=prod", "staging"); code != http.StatusForbidden {
+		t.Fatalf("expect cross-namespace read forbidden, got %d", code)
+	}
+	if code := get("/v1/instances?namespace=prod", ""); code != http.StatusForbidden {
+		t.Fatalf("expect caller without namespace treated as default, got %d", code)
+	}
+	if code := get("/v1/instances", ""); code != http.StatusOK {
+		t.Fatalf("expect default namespace readable without header, got %d", code)
+	}
+	if code := get("/?namespace=*", ""); code != http.StatusForbidden {
+		t.Fatalf("expect reading all namespaces forbidden, got %d", code)
+	}
+	//未开启认证时复制请求头不能绕过命名空间隔离
+	replica := func() int {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/instances?namespace=*", nil)
+		req.Header.Set(replicaHeader, "true")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := replica(); code != http.StatusForbidden {
+		t.Fatalf("expect replica header ignored without auth, got %d", code)
+	}
+	if code := get("/v1/instances?namespace=prod", "prod"); code != http.StatusOK {
+		t.Fatalf("expect same-namespace read allowed, got %d", code)
+	}
+
+	r.AllowCrossNamespace("ops")
+	if code := get("/v1/instances?namespace=prod", "ops"); code != http.StatusOK {
+		t.Fatalf("expect allowed namespace to read across, got %d", code)
+	}
+	resp, err := func() (*http.Response, error) {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/instances?namespace=*", nil)
+		req.Header.Set(namespaceHeader, "ops")
+		return http.DefaultClient.Do(req)
+	}()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all []*Instance
+	_ = json.NewDecoder(resp.Body).Decode(&all)
+	_ = resp.Body.Close()
+	if len(all) != 4 {
+		t.Fatalf("expect all 4 instances, got %v", all)
+	}
+
+	//注销只影响所在命名空间
+	if err := DeregisterNamespace(ts.URL, "staging", "127.0.0.1:8001"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.getServer("staging", "127.0.0.1:8001"); ok {
+		t.Fatal("expect staging instance deregistered")
+	}
+	if _, ok := r.getServer("prod", "127.0.0.1:8001"); !ok {
+		t.Fatal("expect prod instance untouched")
+	}
+}
@@ -1,112 +1,191 @@
 package registry
 
 import (
-	"fmt"
+	"encoding/json"
 	"log"
 	"net"
 	"net/http"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 )
 
 type Registry struct {
-	kv      map[string]*serviceItem
+	kv      map[string]*serviceItem //以instanceKey为键，不同命名空间下的相同地址互不影响
 	mu      sync.Mutex
 	timeout time.Duration
+
+	//实例每次加入、离开或修改元数据，revision加一并关闭changed唤醒所有watch请求
+	revision uint64
+	changed  chan struct{}
+
+	store *store //为nil时不持久化
+
+	peers     []*peer //集群中的其他节点
+	stopPeers chan struct{}
+
+	crossNamespace map[string]bool //允许跨命名空间读取的调用方命名空间
+	auth           *AuthOption     //为nil时不认证
+	nonces         nonceCache      //签名请求中出现过的nonce，防止重放
+	stopCheck      chan struct{}   //主动健康检查，为nil时未开启
+
+	adminOnce sync.Once
+	admin     http.Handler
 }
 
 type serviceItem struct {
-	start   time.Time
-	address string
+	start    time.Time
+	draining bool //被运维摘除流量的实例仍然保持登记，但不再返回给discovery
+
+	//主动健康检查的结果，不健康的实例同样不返回给discovery
+	unhealthy bool
+	failures  int
+	Instance
 }
 
 const (
 	defaultTimeout = 5 * time.Second
 )
 
-func newRegistry(timeout time.Duration) *Registry {
-	return &Registry{timeout: timeout, kv: make(map[string]*serviceItem)}
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout, kv: make(map[string]*serviceItem), revision: 1, changed: make(chan struct{})}
 }
 
-var defaultRegistry = newRegistry(defaultTimeout)
+var defaultRegistry = NewRegistry(defaultTimeout)
 
-func (r *Registry) putServer(addr string) {
+// putServer 登记或续约实例，返回是否为新登记的实例
+func (r *Registry) putServer(ins *Instance) bool {
 
+	ins.normalize()
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	server, ok := r.kv[addr]
+	server, ok := r.kv[ins.key()]
 	if !ok {
-		r.kv[addr] = &serviceItem{address: addr, start: time.Now()}
+		r.kv[ins.key()] = &serviceItem{start: time.Now(), Instance: *ins}
+		r.persist(&record{Op: opPut, Instance: ins})
+		r.notify()
 	} else {
 		server.start = time.Now()
+		if !reflect.DeepEqual(server.Instance, *ins) {
+			server.Instance = *ins
+			r.persist(&record{Op: opPut, Instance: ins})
+			r.notify()
+		}
+	}
+	return !ok
+}
+
+// getServer 返回namespace下addr对应的存活实例
+func (r *Registry) getServer(namespace, addr string) (*Instance, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	item, ok := r.kv[instanceKey(namespace, addr)]
+	if !ok || (r.timeout != 0 && !item.start.Add(r.timeout).After(time.Now())) {
+		return nil, false
+	}
+	ins := item.Instance
+	return &ins, true
+}
+
+func (r *Registry) removeServer(namespace, addr string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := instanceKey(namespace, addr)
+	_, ok := r.kv[key]
+	if ok {
+		delete(r.kv, key)
+		r.persist(&record{Op: opDelete, Namespace: namespace, Addr: addr})
+		r.notify()
 	}
+	return ok
 }
 
-func (r *Registry) aliveServers() []string {
+// aliveServers 返回namespace下提供service服务的存活实例，service为空时返回所有存活实例
+// namespace为AllNamespaces时返回所有命名空间的实例
+func (r *Registry) aliveServers(namespace, service string) []*Instance {
 
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	servers := make([]string, 0)
+	r.expire()
+	return r.alive(namespace, service)
+}
+
+// alive 在持有r.mu时调用
+func (r *Registry) alive(namespace, service string) []*Instance {
+	servers := make([]*Instance, 0)
 	for _, item := range r.kv {
-		if r.timeout == 0 || item.start.Add(r.timeout).After(time.Now()) {
-			servers = append(servers, item.address)
-		} else {
-			delete(r.kv, item.address)
+		if !item.draining && !item.unhealthy && item.inNamespace(namespace) && item.hasService(service) {
+			ins := item.Instance
+			servers = append(servers, &ins)
 		}
 	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].key() < servers[j].key() })
 	return servers
 }
 
 func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
+	if strings.HasPrefix(req.URL.Path, adminPrefix) {
+		r.adminHandler().ServeHTTP(w, req)
+		return
+	}
+	write := req.Method != http.MethodGet && req.Method != http.MethodHead
+	req, peer := r.markPeer(req)
+	if !peer {
+		if err := r.authorize(req, write); err != nil {
+			r.reject(w, req, err)
+			return
+		}
+	}
+	if strings.HasPrefix(req.URL.Path, apiPrefix) {
+		r.serveAPI(w, req)
+		return
+	}
 	switch req.Method {
 	case "GET":
 		log.Println("http get")
-		w.Header().Set("Rpc", strings.Join(r.aliveServers(), ","))
+		//Rpc头只包含地址，兼容旧的客户端；实例的完整元数据以json写在响应体中
+		namespace, ok := r.readNamespace(w, req)
+		if !ok {
+			return
+		}
+		service := req.URL.Query().Get("service")
+		r.watch(w, req, namespace, service)
+		servers := r.aliveServers(namespace, service)
+		addrs := make([]string, 0, len(servers))
+		for _, ins := range servers {
+			addrs = append(addrs, ins.Addr)
+		}
+		w.Header().Set("Rpc", strings.Join(addrs, ","))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(servers)
 	case "POST":
+		ins := instanceFromHeader(req.Header)
+		log.Printf("post addr:%s", ins.Addr)
+		if ins.Addr == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		r.putServer(ins)
+		r.replicate(req, &record{Op: opPut, Namespace: ins.Namespace, Addr: ins.Addr, Instance: ins})
+	case "DELETE":
 		addr := req.Header.Get("Rpc")
-		log.Printf("post addr:%s", addr)
+		namespace := namespaceOf(req.Header.Get(namespaceHeader))
+		log.Printf("delete addr:%s namespace:%s", addr, namespace)
 		if addr == "" {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		r.putServer(addr)
+		if !r.removeServer(namespace, addr) {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		r.replicate(req, &record{Op: opDelete, Namespace: namespace, Addr: addr})
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
-func Heartbeat(registry, addr string, duration time.Duration) {
-
-	if duration == 0 {
-		duration = defaultTimeout - time.Duration(1)*time.Second
-	}
-	err := sendHeart(registry, addr)
-	t := time.NewTicker(duration)
-	wg := new(sync.WaitGroup)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for err == nil {
-			<-t.C
-			err = sendHeart(registry, addr)
-		}
-		fmt.Printf("sendHeart error:%v", err)
-	}()
-	wg.Wait()
-}
-func sendHeart(registry, addr string) error {
-	log.Println(addr, "send heart beat to registry", registry)
-	client := &http.Client{}
-	req, _ := http.NewRequest("POST", registry, nil)
-
-	req.Header.Set("Rpc", addr)
-
-	if resp, err := client.Do(req); err != nil || resp.StatusCode != 200 {
-		log.Fatalf("sendHeart error:%s", resp.Status)
-	}
-	return nil
-}
 
 func StartRegistryServer(lis net.Listener) error {
 	return http.Serve(lis, defaultRegistry)
@@ -1,15 +1,18 @@
 package registry
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"math/rand"
 	"net"
+	"net/http/httptest"
 	"simplerpc/client"
 	"simplerpc/codec"
 	"simplerpc/discovery"
 	"simplerpc/grpc/demo/service"
 	"simplerpc/server"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -35,7 +38,7 @@ func startServer(registry string) {
 	_ = server.Registry(new(FDD))
 	lis, _ := net.Listen("tcp", ":0")
 
-	go Heartbeat(registry, lis.Addr().String(), 0)
+	go Heartbeat(registry, lis.Addr().String(), 0, server.Services()...)
 	server.Accept(lis)
 
 }
@@ -57,7 +60,7 @@ func TestStartRegistryServer(t *testing.T) {
 
 	time.Sleep(5 * time.Second)
 
-	d := discovery.NewServerDiscovery(registry_http, time.Minute)
+	d := discovery.NewServerDiscovery(registry_http, time.Minute, &discovery.Option{Service: "FDD"})
 	client, err := client.Dial("tcp", d, &codec.Option{
 		CodecType: codec.ProtoType, MagicNumber: codec.MagicNum})
 	//client, err := client.Dial("tcp", d)
@@ -81,3 +84,95 @@ func TestStartRegistryServer(t *testing.T) {
 	wg.Wait()
 
 }
+
+func TestRegistryServiceFilter(t *testing.T) {
+	r := NewRegistry(time.Minute)
+	post := func(addr, services string) {
+		req := httptest.NewRequest("POST", "/", nil)
+		req.Header.Set("Rpc", addr)
+		req.Header.Set("Rpc-Services", services)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	get := func(target string) string {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w.Header().Get("Rpc")
+	}
+	post("127.0.0.1:8001", "FDD,Health")
+	post("127.0.0.1:8002", "Cache")
+
+	if servers := get("/?service=FDD"); servers != "127.0.0.1:8001" {
+		t.Fatalf("expect only FDD server, got %q", servers)
+	}
+	if servers := get("/?service=Cache"); servers != "127.0.0.1:8002" {
+		t.Fatalf("expect only Cache server, got %q", servers)
+	}
+	if servers := get("/?service=Unknown"); servers != "" {
+		t.Fatalf("expect no server, got %q", servers)
+	}
+	if servers := strings.Split(get("/"), ","); len(servers) != 2 {
+		t.Fatalf("expect all servers, got %v", servers)
+	}
+}
+
+func TestRegistryInstanceMetadata(t *testing.T) {
+	r := NewRegistry(time.Minute)
+	req := httptest.NewRequest("POST", "/", nil)
+	(&Instance{
+		Addr:     "127.0.0.1:8001",
+		Services: []string{"FDD"},
+		Weight:   3,
+		Version:  "v2",
+		Zone:     "sh",
+		Tags:     []string{"canary"},
+		Codecs:   []string{codec.GobType, codec.ProtoType},
+	}).setHeader(req.Header)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	//只带Rpc头的旧版心跳使用默认权重
+	req = httptest.NewRequest("POST", "/", nil)
+	req.Header.Set("Rpc", "127.0.0.1:8002")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	var instances []*discovery.Instance
+	if err := json.NewDecoder(w.Body).Decode(&instances); err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 2 {
+		t.Fatalf("expect 2 instances, got %d", len(instances))
+	}
+	ins := instances[0]
+	if ins.Weight != 3 || ins.Version != "v2" || ins.Zone != "sh" || len(ins.Tags) != 1 || len(ins.Codecs) != 2 {
+		t.Fatalf("unexpected metadata: %+v", ins)
+	}
+	if instances[1].Weight != defaultWeight {
+		t.Fatalf("expect default weight, got %d", instances[1].Weight)
+	}
+}
+
+func TestDeregister(t *testing.T) {
+	r := NewRegistry(time.Minute)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	addr := "127.0.0.1:9999"
+	go Heartbeat(ts.URL, addr, 50*time.Millisecond, "FDD")
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := r.getServer(DefaultNamespace, addr); !ok {
+		t.Fatal("expect instance registered by heartbeat")
+	}
+
+	if err := Deregister(ts.URL, addr); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.getServer(DefaultNamespace, addr); ok {
+		t.Fatal("expect instance removed immediately")
+	}
+	//心跳已停止，实例不会被重新登记
+	time.Sleep(150 * time.Millisecond)
+	if _, ok := r.getServer(DefaultNamespace, addr); ok {
+		t.Fatal("expect heartbeat stopped after deregister")
+	}
+}
@@ -0,0 +1,251 @@
+package registry
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StoreOption 注册中心持久化的配置
+// 实例的变更追加写入日志，定期写快照并截断日志；重启时从快照和日志恢复实例，
+// 恢复的实例在Grace内不会因为心跳超时被剔除，等待服务器重新发送心跳
+type StoreOption struct {
+	Dir              string        //存放快照和日志的目录
+	SnapshotInterval time.Duration //写快照的间隔，默认1分钟
+	Grace            time.Duration //恢复的实例的宽限期，默认为心跳超时的2倍
+}
+
+const (
+	snapshotFile            = "snapshot.json"
+	logFile                 = "registry.log"
+	defaultSnapshotInterval = time.Minute
+)
+
+const (
+	opPut     = "put"
+	opDelete  = "delete"
+	opDrain   = "drain"
+	opUndrain = "undrain"
+)
+
+type record struct {
+	Op        string    `json:"op"`
+	Namespace string    `json:"namespace,omitempty"`
+	Addr      string    `json:"addr,omitempty"`
+	Instance  *Instance `json:"instance,omitempty"`
+}
+
+// storedInstance 快照中的实例，同时保存运维设置的摘除流量状态
+type storedInstance struct {
+	Instance
+	Draining bool `json:"draining,omitempty"`
+}
+
+type store struct {
+	dir  string
+	file *os.File
+	stop chan struct{}
+	done chan struct{} //snapshotLoop退出时关闭
+}
+
+// NewPersistentRegistry 创建一个带磁盘持久化的注册中心，并从opt.Dir中恢复上一次的实例
+func NewPersistentRegistry(timeout time.Duration, opt *StoreOption) (*Registry, error) {
+	if opt == nil || opt.Dir == "" {
+		return nil, errors.New("rpc registry: store dir is required")
+	}
+	interval := opt.SnapshotInterval
+	if interval <= 0 {
+		interval = defaultSnapshotInterval
+	}
+	grace := opt.Grace
+	if grace <= 0 {
+		grace = 2 * timeout
+	}
+	if err := os.MkdirAll(opt.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	r := NewRegistry(timeout)
+	instances, err := loadInstances(opt.Dir)
+	if err != nil {
+		return nil, err
+	}
+	//恢复的实例视作在grace-timeout之前发过心跳，从而在grace之后才会过期
+	restored := time.Now().Add(grace - timeout)
+	for _, ins := range instances {
+		ins.normalize()
+		r.kv[ins.key()] = &serviceItem{start: restored, draining: ins.Draining, Instance: ins.Instance}
+	}
+	if len(instances) > 0 {
+		log.Printf("rpc registry: restored %d instances from %s", len(instances), opt.Dir)
+	}
+
+	s := &store{dir: opt.Dir, stop: make(chan struct{}), done: make(chan struct{})}
+	r.store = s
+	//恢复后立即写一次快照，把日志合并进来
+	r.mu.Lock()
+	err = r.snapshot()
+	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	go r.snapshotLoop(interval, s)
+	return r, nil
+}
+
+// loadInstances 读取快照，再按顺序重放日志
+func loadInstances(dir string) ([]*storedInstance, error) {
+	instances := make(map[string]*storedInstance)
+	data, err := os.ReadFile(filepath.Join(dir, snapshotFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		var snapshot []*storedInstance
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return nil, err
+		}
+		for _, ins := range snapshot {
+			instances[ins.key()] = ins
+		}
+	}
+
+	f, err := os.Open(filepath.Join(dir, logFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if f != nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			rec := new(record)
+			if err := json.Unmarshal(scanner.Bytes(), rec); err != nil {
+				//最后一条记录可能因为进程退出只写了一半，忽略即可
+				log.Printf("rpc registry: skip broken log record: %v", err)
+				continue
+			}
+			switch rec.Op {
+			case opPut:
+				if rec.Instance == nil {
+					continue
+				}
+				//续约和变更不会取消摘除
+				if old, ok := instances[rec.Instance.key()]; ok {
+					old.Instance = *rec.Instance
+				} else {
+					instances[rec.Instance.key()] = &storedInstance{Instance: *rec.Instance}
+				}
+			case opDelete:
+				delete(instances, instanceKey(rec.Namespace, rec.Addr))
+			case opDrain, opUndrain:
+				if ins, ok := instances[instanceKey(rec.Namespace, rec.Addr)]; ok {
+					ins.Draining = rec.Op == opDrain
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	list := make([]*storedInstance, 0, len(instances))
+	for _, ins := range instances {
+		list = append(list, ins)
+	}
+	return list, nil
+}
+
+// persist 在持有r.mu时调用，把一次变更追加到日志
+func (r *Registry) persist(rec *record) {
+	if r.store == nil || r.store.file == nil {
+		return
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		log.Printf("rpc registry: encode log record error: %v", err)
+		return
+	}
+	if _, err := r.store.file.Write(append(data, '\n')); err != nil {
+		log.Printf("rpc registry: append log error: %v", err)
+	}
+}
+
+// snapshot 在持有r.mu时调用，把当前所有实例写入快照并截断日志
+func (r *Registry) snapshot() error {
+	s := r.store
+	instances := make([]*storedInstance, 0, len(r.kv))
+	for _, item := range r.kv {
+		instances = append(instances, &storedInstance{Instance: item.Instance, Draining: item.draining})
+	}
+	data, err := json.Marshal(instances)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(s.dir, snapshotFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, snapshotFile)); err != nil {
+		return err
+	}
+	if s.file != nil {
+		_ = s.file.Close()
+	}
+	s.file, err = os.OpenFile(filepath.Join(s.dir, logFile), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	return err
+}
+
+func (r *Registry) snapshotLoop(interval time.Duration, s *store) {
+	defer close(s.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+			r.mu.Lock()
+			//等锁期间可能已经Close
+			if r.store != s {
+				r.mu.Unlock()
+				return
+			}
+			if err := r.snapshot(); err != nil {
+				log.Printf("rpc registry: snapshot error: %v", err)
+			}
+			r.mu.Unlock()
+		}
+	}
+}
+
+// Close 停止向peer复制和健康检查，写最后一次快照并关闭持久化
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopHealthCheck()
+	if r.stopPeers != nil {
+		close(r.stopPeers)
+		r.stopPeers = nil
+		r.peers = nil
+	}
+	s := r.store
+	if s == nil {
+		return nil
+	}
+	err := r.snapshot()
+	if s.file != nil {
+		_ = s.file.Close()
+	}
+	r.store = nil
+	close(s.stop)
+	//等待snapshotLoop退出，之后不会再写该目录
+	r.mu.Unlock()
+	<-s.done
+	r.mu.Lock()
+	return err
+}
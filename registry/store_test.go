@@ -0,0 +1,108 @@
+package registry
+
+import (
+	"testing"
+	"time"
+)
+
+// crash 停止持久化但不写最后一次快照
+func (r *Registry) crash() {
+	r.mu.Lock()
+	s := r.store
+	r.store = nil
+	_ = s.file.Close()
+	r.mu.Unlock()
+	close(s.stop)
+	<-s.done
+}
+
+func TestPersistentRegistry(t *testing.T) {
+	dir := t.TempDir()
+	opt := &StoreOption{Dir: dir, SnapshotInterval: time.Hour, Grace: 300 * time.Millisecond}
+	r, err := NewPersistentRegistry(100*time.Millisecond, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.putServer(&Instance{Addr: "127.0.0.1:8001", Services: []string{"FDD"}, Weight: 1})
+	r.putServer(&Instance{Addr: "127.0.0.1:8002", Services: []string{"FDD"}, Weight: 1})
+	r.removeServer(DefaultNamespace, "127.0.0.1:8002")
+	r.putServer(&Instance{Addr: "127.0.0.1:8001", Services: []string{"FDD"}, Weight: 3})
+	r.putServer(&Instance{Addr: "127.0.0.1:8003", Services: []string{"FDD"}, Weight: 1})
+	r.setDraining(DefaultNamespace, "127.0.0.1:8003", true)
+	r.putServer(&Instance{Addr: "127.0.0.1:8003", Services: []string{"FDD"}, Weight: 2})
+
+	//不写最后一次快照，模拟注册中心进程直接退出，只能依赖快照和日志恢复
+	r.crash()
+	defer r.Close()
+	restored, err := NewPersistentRegistry(100*time.Millisecond, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	time.Sleep(150 * time.Millisecond)
+	servers := restored.aliveServers(DefaultNamespace, "FDD")
+	if len(servers) != 1 || servers[0].Addr != "127.0.0.1:8001" || servers[0].Weight != 3 {
+		t.Fatalf("expect restored instance within grace, got %v", servers)
+	}
+	if views := restored.instanceViews(); len(views) != 2 || !views[1].Draining || views[1].Weight != 2 {
+		t.Fatalf("expect drained instance restored as draining, got %+v", views)
+	}
+	time.Sleep(250 * time.Millisecond)
+	if servers := restored.aliveServers(DefaultNamespace, ""); len(servers) != 0 {
+		t.Fatalf("expect restored instance expired after grace, got %v", servers)
+	}
+}
+
+func TestPersistentRegistryNamespaces(t *testing.T) {
+	dir := t.TempDir()
+	opt := &StoreOption{Dir: dir, SnapshotInterval: time.Hour}
+	r, err := NewPersistentRegistry(time.Minute, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//同一个地址登记在不同命名空间下，快照中的两条记录都要恢复
+	r.putServer(&Instance{Addr: "127.0.0.1:8001", Namespace: "staging", Weight: 1})
+	r.putServer(&Instance{Addr: "127.0.0.1:8001", Namespace: "prod", Weight: 1})
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := NewPersistentRegistry(time.Minute, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	for _, namespace := range []string{"staging", "prod"} {
+		if _, ok := restored.getServer(namespace, "127.0.0.1:8001"); !ok {
+			t.Fatalf("expect instance restored in namespace %s", namespace)
+		}
+	}
+}
+
+func TestPersistentRegistrySnapshot(t *testing.T) {
+	dir := t.TempDir()
+	opt := &StoreOption{Dir: dir, SnapshotInterval: 50 * time.Millisecond}
+	r, err := NewPersistentRegistry(time.Minute, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.putServer(&Instance{Addr: "127.0.0.1:8001", Weight: 1})
+	time.Sleep(100 * time.Millisecond)
+	r.putServer(&Instance{Addr: "127.0.0.1:8002", Weight: 1})
+	r.setDraining(DefaultNamespace, "127.0.0.1:8002", true)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := NewPersistentRegistry(time.Minute, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	if servers := restored.aliveServers(DefaultNamespace, ""); len(servers) != 1 || servers[0].Addr != "127.0.0.1:8001" {
+		t.Fatalf("expect drained instance kept out of rotation, got %v", servers)
+	}
+	if views := restored.instanceViews(); len(views) != 2 || !views[1].Draining {
+		t.Fatalf("expect 2 restored instances with drain state, got %+v", views)
+	}
+}
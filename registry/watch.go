@@ -0,0 +1,96 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultWatchWait = 30 * time.Second
+	maxWatchWait     = 5 * time.Minute
+)
+
+// notify 在持有r.mu时调用，记录一次变更并唤醒等待中的watch请求
+func (r *Registry) notify() {
+	r.revision++
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// expire 在持有r.mu时调用，移除心跳超时的实例
+func (r *Registry) expire() {
+	if r.timeout == 0 {
+		return
+	}
+	for key, item := range r.kv {
+		if !item.start.Add(r.timeout).After(time.Now()) {
+			delete(r.kv, key)
+			r.persist(&record{Op: opDelete, Namespace: item.Namespace, Addr: item.Addr})
+			r.notify()
+		}
+	}
+}
+
+// waitChange 阻塞到namespace下提供service的实例列表相对index发生变化或者等待超时，返回当前的revision
+// index已经落后时立即返回；等待期间其他服务的变化不会唤醒请求，定期检查心跳超时，保证实例过期也能及时通知到watch请求
+// ctx结束（调用方断开连接）时立即返回
+func (r *Registry) waitChange(ctx context.Context, index uint64, wait time.Duration, namespace, service string) uint64 {
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	var expireC <-chan time.Time
+	if r.timeout > 0 {
+		t := time.NewTicker(r.timeout / 10)
+		defer t.Stop()
+		expireC = t.C
+	}
+	var last []byte
+	for {
+		r.mu.Lock()
+		r.expire()
+		revision, changed := r.revision, r.changed
+		view, _ := json.Marshal(r.alive(namespace, service))
+		r.mu.Unlock()
+		if last == nil {
+			if revision > index {
+				return revision
+			}
+			last = view
+		} else if !bytes.Equal(view, last) {
+			return revision
+		}
+		select {
+		case <-changed:
+		case <-expireC:
+		case <-deadline.C:
+			return revision
+		case <-ctx.Done():
+			return revision
+		}
+	}
+}
+
+// watch 处理列表请求上的长轮询参数，并在响应头Rpc-Index中返回当前revision
+// 请求带上一次拿到的index时，阻塞到注册信息发生变化或wait超时后再返回列表
+func (r *Registry) watch(w http.ResponseWriter, req *http.Request, namespace, service string) {
+	q := req.URL.Query()
+	index, err := strconv.ParseUint(q.Get("index"), 10, 64)
+	if err != nil {
+		r.mu.Lock()
+		revision := r.revision
+		r.mu.Unlock()
+		w.Header().Set("Rpc-Index", strconv.FormatUint(revision, 10))
+		return
+	}
+	wait, err := time.ParseDuration(q.Get("wait"))
+	if err != nil || wait <= 0 {
+		wait = defaultWatchWait
+	}
+	if wait > maxWatchWait {
+		wait = maxWatchWait
+	}
+	w.Header().Set("Rpc-Index", strconv.FormatUint(r.waitChange(req.Context(), index, wait, namespace, service), 10))
+}
@@ -0,0 +1,121 @@
+package registry
+
+import (
+	"context"
+	"net/http/httptest"
+	"simplerpc/discovery"
+	"testing"
+	"time"
+)
+
+func TestWatchExpire(t *testing.T) {
+	r := NewRegistry(200 * time.Millisecond)
+	r.putServer(&Instance{Addr: "127.0.0.1:8001", Weight: defaultWeight})
+	r.mu.Lock()
+	index := r.revision
+	r.mu.Unlock()
+
+	start := time.Now()
+	if revision := r.waitChange(context.Background(), index, 5*time.Second, DefaultNamespace, ""); revision <= index {
+		t.Fatalf("expect revision to advance after expiry, got %d", revision)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expiry noticed too late: %v", elapsed)
+	}
+	if servers := r.aliveServers(DefaultNamespace, ""); len(servers) != 0 {
+		t.Fatalf("expect instance expired, got %v", servers)
+	}
+}
+
+func TestDiscoveryWatch(t *testing.T) {
+	r := NewRegistry(time.Minute)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	d := discovery.NewServerDiscovery(ts.URL, time.Minute, &discovery.Option{Service: "FDD"})
+	updates := d.Subscribe()
+	stop := d.Watch()
+	defer stop()
+
+	next := func() []*discovery.Instance {
+		select {
+		case instances := <-updates:
+			return instances
+		case <-time.After(time.Second):
+			t.Fatal("no update pushed from registry")
+			return nil
+		}
+	}
+	if instances := next(); len(instances) != 0 {
+		t.Fatalf("expect empty initial list, got %d", len(instances))
+	}
+
+	r.putServer(&Instance{Addr: "127.0.0.1:8001", Services: []string{"FDD"}, Weight: defaultWeight})
+	if instances := next(); len(instances) != 1 || instances[0].Addr != "127.0.0.1:8001" {
+		t.Fatalf("expect joined instance, got %v", instances)
+	}
+
+	r.putServer(&Instance{Addr: "127.0.0.1:8001", Services: []string{"FDD"}, Weight: 5})
+	if instances := next(); len(instances) != 1 || instances[0].Weight != 5 {
+		t.Fatalf("expect metadata change, got %v", instances)
+	}
+
+	r.removeServer(DefaultNamespace, "127.0.0.1:8001")
+	if instances := next(); len(instances) != 0 {
+		t.Fatalf("expect instance left, got %v", instances)
+	}
+	if _, err := d.Get(); err == nil {
+		t.Fatal("expect no available servers after leave")
+	}
+}
+
+func TestWatchFilter(t *testing.T) {
+	r := NewRegistry(time.Minute)
+	r.mu.Lock()
+	index := r.revision
+	r.mu.Unlock()
+	done := make(chan uint64, 1)
+	go func() {
+		done <- r.waitChange(context.Background(), index, 5*time.Second, DefaultNamespace, "FDD")
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	//其他服务和其他命名空间的变化不唤醒watch
+	r.putServer(&Instance{Addr: "127.0.0.1:8001", Services: []string{"Other"}, Weight: defaultWeight})
+	r.putServer(&Instance{Namespace: "prod", Addr: "127.0.0.1:8002", Services: []string{"FDD"}, Weight: defaultWeight})
+	select {
+	case revision := <-done:
+		t.Fatalf("watch woken by unrelated change, revision %d", revision)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	r.putServer(&Instance{Addr: "127.0.0.1:8003", Services: []string{"FDD"}, Weight: defaultWeight})
+	select {
+	case revision := <-done:
+		if revision <= index {
+			t.Fatalf("expect revision to advance, got %d", revision)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watch not woken by a change of the watched service")
+	}
+}
+
+func TestWatchCanceled(t *testing.T) {
+	r := NewRegistry(time.Minute)
+	r.mu.Lock()
+	index := r.revision
+	r.mu.Unlock()
+	//调用方断开连接后长轮询立即结束，不再等到wait超时
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan uint64, 1)
+	go func() {
+		done <- r.waitChange(ctx, index, time.Minute, DefaultNamespace, "")
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watch not ended after the caller went away")
+	}
+}
@@ -0,0 +1,163 @@
+// Package schema 描述服务、方法以及参数和返回值的类型，
+// 服务器的反射服务返回这些描述，客户端据此在调用前检查自己的类型与服务器是否一致
+package schema
+
+import (
+	"fmt"
+	"reflect"
+)
+
+const (
+	ReflectionService = "Reflection"
+	ListMethod        = "Reflection.ListServices"
+)
+
+// ListRequest 反射服务的请求，Service为空时返回所有服务
+type ListRequest struct {
+	Service string
+}
+
+type ListResponse struct {
+	Services []*ServiceInfo
+}
+
+type ServiceInfo struct {
+	Name    string
+	Methods []*MethodInfo
+}
+
+type MethodInfo struct {
+	Name  string
+	Arg   *TypeInfo
+	Reply *TypeInfo
+}
+
+// TypeInfo 类型的描述，指针、切片、数组和map通过Elem和Key递归描述，结构体列出所有导出字段
+// 递归引用自身的结构体在第二次出现时只给出Name并将Ref置为true
+type TypeInfo struct {
+	Name   string //如int、*service.Foo、[]string
+	Kind   string //reflect.Kind的名字
+	Len    int    //数组的长度
+	Elem   *TypeInfo
+	Key    *TypeInfo
+	Fields []*FieldInfo
+	Ref    bool
+}
+
+type FieldInfo struct {
+	Name string
+	Tag  string
+	Type *TypeInfo
+}
+
+// Describe 生成t的描述
+func Describe(t reflect.Type) *TypeInfo {
+	return describe(t, map[reflect.Type]bool{})
+}
+
+// describe seen记录当前路径上正在展开的结构体，避免递归类型无限展开
+func describe(t reflect.Type, seen map[reflect.Type]bool) *TypeInfo {
+	info := &TypeInfo{Name: t.String(), Kind: t.Kind().String()}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice:
+		info.Elem = describe(t.Elem(), seen)
+	case reflect.Array:
+		info.Len = t.Len()
+		info.Elem = describe(t.Elem(), seen)
+	case reflect.Map:
+		info.Key = describe(t.Key(), seen)
+		info.Elem = describe(t.Elem(), seen)
+	case reflect.Struct:
+		if seen[t] {
+			info.Ref = true
+			return info
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		info.Fields = make([]*FieldInfo, 0, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			info.Fields = append(info.Fields, &FieldInfo{Name: field.Name, Tag: string(field.Tag), Type: describe(field.Type, seen)})
+		}
+	}
+	return info
+}
+
+// Compatible 检查t与other在编码后是否一致，不一致时返回第一处差异
+// 编码方式都会展开指针，因此忽略指针的差异以及类型所在的包；结构体的导出字段必须一一对应
+func (t *TypeInfo) Compatible(other *TypeInfo) error {
+	return compatible(t, other, "")
+}
+
+func compatible(a, b *TypeInfo, path string) error {
+	a, b = a.deref(), b.deref()
+	if a.Ref || b.Ref {
+		//递归引用在外层已经比较过
+		return nil
+	}
+	if kindGroup(a.Kind) != kindGroup(b.Kind) {
+		return fmt.Errorf("%s: %s does not match %s", pathOf(path), a.Name, b.Name)
+	}
+	switch kindGroup(a.Kind) {
+	case "list":
+		return compatible(a.Elem, b.Elem, path+"[]")
+	case "map":
+		if err := compatible(a.Key, b.Key, path+"[key]"); err != nil {
+			return err
+		}
+		return compatible(a.Elem, b.Elem, path+"[]")
+	case "struct":
+		fields := make(map[string]*FieldInfo, len(b.Fields))
+		for _, f := range b.Fields {
+			fields[f.Name] = f
+		}
+		for _, f := range a.Fields {
+			other, ok := fields[f.Name]
+			if !ok {
+				return fmt.Errorf("%s: field %s missing in %s", pathOf(path), f.Name, b.Name)
+			}
+			if err := compatible(f.Type, other.Type, path+"."+f.Name); err != nil {
+				return err
+			}
+			delete(fields, f.Name)
+		}
+		for name := range fields {
+			return fmt.Errorf("%s: field %s missing in %s", pathOf(path), name, a.Name)
+		}
+	}
+	return nil
+}
+
+func (t *TypeInfo) deref() *TypeInfo {
+	for t.Kind == reflect.Ptr.String() && t.Elem != nil {
+		t = t.Elem
+	}
+	return t
+}
+
+// kindGroup 编码后没有区别的Kind归为一组，如int和int64、数组和切片
+func kindGroup(kind string) string {
+	switch kind {
+	case "int", "int8", "int16", "int32", "int64":
+		return "int"
+	case "uint", "uint8", "uint16", "uint32", "uint64", "uintptr":
+		return "uint"
+	case "float32", "float64":
+		return "float"
+	case "complex64", "complex128":
+		return "complex"
+	case "array", "slice":
+		return "list"
+	}
+	return kind
+}
+
+func pathOf(path string) string {
+	if path == "" {
+		return "type"
+	}
+	return path[1:]
+}
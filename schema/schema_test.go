@@ -0,0 +1,42 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+type node struct {
+	Value int64
+	Next  *node
+	Tags  map[string][]byte
+}
+
+type otherNode struct {
+	Value int
+	Next  *otherNode
+	Tags  map[string][]uint8
+}
+
+func TestCompatible(t *testing.T) {
+	describe := func(v interface{}) *TypeInfo { return Describe(reflect.TypeOf(v)) }
+	cases := []struct {
+		a, b interface{}
+		ok   bool
+	}{
+		{1, int64(1), true},
+		{new(int), 1, true},
+		{[3]string{}, []string{}, true},
+		{node{}, &otherNode{}, true},
+		{1, uint(1), false},
+		{1, "", false},
+		{map[string]int{}, map[int]int{}, false},
+		{node{}, struct{ Value int }{}, false},
+		{struct{ Value int }{}, struct{ Value string }{}, false},
+	}
+	for _, c := range cases {
+		err := describe(c.a).Compatible(describe(c.b))
+		if (err == nil) != c.ok {
+			t.Fatalf("%T vs %T: expect compatible=%v, got %v", c.a, c.b, c.ok, err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// 健康检查服务，NewServer时自动注册，注册中心和负载均衡通过调用Health.Check判断服务器能否正常处理请求
+// Service为空时检查整个服务器，否则检查指定的服务；业务代码通过SetServingStatus设置各服务的状态，
+// Shutdown开始时所有状态变为NOT_SERVING，用于在关闭前通知探测方摘除流量
+
+const (
+	HealthService     = "Health"
+	HealthCheckMethod = "Health.Check"
+	HealthWatchMethod = "Health.Watch"
+)
+
+type HealthStatus int32
+
+const (
+	HealthUnknown    HealthStatus = 0
+	HealthServing    HealthStatus = 1
+	HealthNotServing HealthStatus = 2
+)
+
+func (s HealthStatus) String() string {
+	switch s {
+	case HealthServing:
+		return "SERVING"
+	case HealthNotServing:
+		return "NOT_SERVING"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+type HealthCheckRequest struct {
+	Service string
+}
+
+type HealthCheckResponse struct {
+	Status HealthStatus
+}
+
+// HealthWatchRequest 长轮询请求，状态与Status不同时立即返回，否则等到状态变化或Wait超时；
+// 服务器开始关闭后立即返回，避免长轮询拖住Shutdown
+type HealthWatchRequest struct {
+	Service string
+	Status  HealthStatus  //调用方上一次拿到的状态
+	Wait    time.Duration //默认30秒，最长5分钟
+}
+
+const (
+	defaultHealthWait = 30 * time.Second
+	maxHealthWait     = 5 * time.Minute
+)
+
+type Health struct {
+	server *Server
+
+	mu       sync.Mutex
+	statuses map[string]HealthStatus //显式设置过的状态，""为整个服务器
+	changed  chan struct{}
+	shut     bool
+}
+
+func newHealth(server *Server) *Health {
+	return &Health{
+		server:   server,
+		statuses: map[string]HealthStatus{"": HealthServing},
+		changed:  make(chan struct{}),
+	}
+}
+
+// Check 返回服务的状态，服务器上已注册但没有设置过状态的服务为SERVING，不存在的服务为UNKNOWN
+func (h *Health) Check(req *HealthCheckRequest, reply *HealthCheckResponse) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	reply.Status = h.status(req.Service)
+	return nil
+}
+
+func (h *Health) Watch(req *HealthWatchRequest, reply *HealthCheckResponse) error {
+	wait := req.Wait
+	if wait <= 0 {
+		wait = defaultHealthWait
+	}
+	if wait > maxHealthWait {
+		wait = maxHealthWait
+	}
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	for {
+		h.mu.Lock()
+		status, changed, shut := h.status(req.Service), h.changed, h.shut
+		h.mu.Unlock()
+		reply.Status = status
+		if status != req.Status || shut {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return nil
+		}
+	}
+}
+
+// status 在持有h.mu时调用
+func (h *Health) status(service string) HealthStatus {
+	if status, ok := h.statuses[service]; ok {
+		return status
+	}
+	if _, ok := h.server.services.Load(service); ok {
+		return h.statuses[""]
+	}
+	return HealthUnknown
+}
+
+func (h *Health) setStatus(service string, status HealthStatus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.shut {
+		return
+	}
+	if old, ok := h.statuses[service]; ok && old == status {
+		return
+	}
+	h.statuses[service] = status
+	h.notify()
+}
+
+// forget 服务被移除时清除它单独设置的状态，之后该服务为UNKNOWN
+func (h *Health) forget(service string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.shut {
+		return
+	}
+	delete(h.statuses, service)
+	h.notify()
+}
+
+// shutdown 将所有服务标记为NOT_SERVING，之后的SetServingStatus不再生效
+func (h *Health) shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.shut = true
+	h.server.services.Range(func(key, _ interface{}) bool {
+		h.statuses[key.(string)] = HealthNotServing
+		return true
+	})
+	for service := range h.statuses {
+		h.statuses[service] = HealthNotServing
+	}
+	h.notify()
+}
+
+// notify 在持有h.mu时调用，唤醒所有Watch请求
+func (h *Health) notify() {
+	close(h.changed)
+	h.changed = make(chan struct{})
+}
+
+// SetServingStatus 设置服务的健康状态，service为空时设置整个服务器的状态
+// 未单独设置过状态的服务跟随整个服务器的状态
+func (s *Server) SetServingStatus(service string, status HealthStatus) {
+	s.health.setStatus(service, status)
+}
+
+func SetServingStatus(service string, status HealthStatus) {
+	defaultServer.SetServingStatus(service, status)
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"net"
+	"simplerpc/client"
+	"simplerpc/codec"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	s := NewServer()
+	if err := s.Registry(new(Slow)); err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Accept(lis)
+	c, err := client.DialAddr("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	check := func(service string) HealthStatus {
+		reply := new(HealthCheckResponse)
+		if err := c.Call(HealthCheckMethod, &HealthCheckRequest{Service: service}, reply); err != nil {
+			t.Fatal(err)
+		}
+		return reply.Status
+	}
+	if status := check(""); status != HealthServing {
+		t.Fatalf("expect server SERVING, got %s", status)
+	}
+	if status := check("Slow"); status != HealthServing {
+		t.Fatalf("expect registered service SERVING, got %s", status)
+	}
+	if status := check("Unknown"); status != HealthUnknown {
+		t.Fatalf("expect unknown service UNKNOWN, got %s", status)
+	}
+	s.SetServingStatus("Slow", HealthNotServing)
+	if status := check("Slow"); status != HealthNotServing {
+		t.Fatalf("expect NOT_SERVING after set, got %s", status)
+	}
+	if status := check(""); status != HealthServing {
+		t.Fatalf("expect server status untouched, got %s", status)
+	}
+
+	//Watch阻塞到状态变化
+	watch := make(chan HealthStatus, 1)
+	go func() {
+		reply := new(HealthCheckResponse)
+		_ = c.Call(HealthWatchMethod, &HealthWatchRequest{Service: "Slow", Status: HealthNotServing, Wait: time.Second}, reply)
+		watch <- reply.Status
+	}()
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case status := <-watch:
+		t.Fatalf("expect watch to block, got %s", status)
+	default:
+	}
+	s.SetServingStatus("Slow", HealthServing)
+	select {
+	case status := <-watch:
+		if status != HealthServing {
+			t.Fatalf("expect watch to return SERVING, got %s", status)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("watch not woken by status change")
+	}
+
+	//Health的参数和返回值不是proto消息，proto编码下调用报错而不是返回空的状态
+	pc, err := client.DialAddr("tcp", lis.Addr().String(), &codec.Option{MagicNumber: codec.MagicNum, CodecType: codec.ProtoType})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	if err := pc.Call(HealthCheckMethod, &HealthCheckRequest{}, new(HealthCheckResponse)); err == nil {
+		t.Fatal("expect error calling Health.Check over protobuf")
+	}
+
+	if services := s.Services(); len(services) != 1 || services[0] != "Slow" {
+		t.Fatalf("expect builtin services excluded, got %v", services)
+	}
+
+	//关闭时正在watch的探测方收到NOT_SERVING，已经是NOT_SERVING的watch也立即返回
+	s.SetServingStatus("Slow", HealthNotServing)
+	stalled := make(chan HealthStatus, 1)
+	go func() {
+		reply := new(HealthCheckResponse)
+		_ = c.Call(HealthWatchMethod, &HealthWatchRequest{Service: "Slow", Status: HealthNotServing, Wait: time.Minute}, reply)
+		stalled <- reply.Status
+	}()
+	go func() {
+		reply := new(HealthCheckResponse)
+		_ = c.Call(HealthWatchMethod, &HealthWatchRequest{Status: HealthServing, Wait: time.Second}, reply)
+		watch <- reply.Status
+	}()
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	s.Shutdown()
+	if status := <-watch; status != HealthNotServing {
+		t.Fatalf("expect NOT_SERVING on shutdown, got %s", status)
+	}
+	select {
+	case status := <-stalled:
+		if status != HealthNotServing {
+			t.Fatalf("expect NOT_SERVING on shutdown, got %s", status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watch not woken by shutdown")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("expect shutdown not held by watch, took %v", d)
+	}
+}
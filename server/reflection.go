@@ -0,0 +1,52 @@
+package server
+
+import (
+	"simplerpc/schema"
+	"sort"
+)
+
+// 反射服务，NewServer时自动注册，返回服务器上注册的所有服务、方法以及参数和返回值的类型描述，
+// 供命令行工具等在不知道服务定义的情况下发现服务器提供的接口
+
+const (
+	ReflectionService    = schema.ReflectionService
+	ReflectionListMethod = schema.ListMethod
+)
+
+type (
+	ReflectionRequest  = schema.ListRequest
+	ReflectionResponse = schema.ListResponse
+	ServiceInfo        = schema.ServiceInfo
+	MethodInfo         = schema.MethodInfo
+	TypeInfo           = schema.TypeInfo
+	FieldInfo          = schema.FieldInfo
+)
+
+type Reflection struct {
+	server *Server
+}
+
+func (r *Reflection) ListServices(req *ReflectionRequest, reply *ReflectionResponse) error {
+	reply.Services = make([]*ServiceInfo, 0)
+	r.server.services.Range(func(key, value interface{}) bool {
+		if req.Service == "" || req.Service == key.(string) {
+			reply.Services = append(reply.Services, value.(*Service).info())
+		}
+		return true
+	})
+	sort.Slice(reply.Services, func(i, j int) bool { return reply.Services[i].Name < reply.Services[j].Name })
+	return nil
+}
+
+func (s *Service) info() *ServiceInfo {
+	info := &ServiceInfo{Name: s.name, Methods: make([]*MethodInfo, 0, len(s.methods))}
+	for name, mType := range s.methods {
+		info.Methods = append(info.Methods, &MethodInfo{
+			Name:  name,
+			Arg:   schema.Describe(mType.argType),
+			Reply: schema.Describe(mType.rlyType),
+		})
+	}
+	sort.Slice(info.Methods, func(i, j int) bool { return info.Methods[i].Name < info.Methods[j].Name })
+	return info
+}
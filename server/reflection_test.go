@@ -0,0 +1,71 @@
+package server
+
+import (
+	"net"
+	"simplerpc/client"
+	"testing"
+)
+
+type Tree struct {
+	Value    int `json:"value"`
+	Children []*Tree
+	hidden   bool
+}
+
+type Forest int
+
+func (f *Forest) Grow(tree *Tree, rly *map[string]Tree) error {
+	return nil
+}
+
+func TestReflection(t *testing.T) {
+	s := NewServer()
+	_ = s.Registry(new(Slow))
+	_ = s.Registry(new(Forest))
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Accept(lis)
+	defer s.Shutdown()
+	c, err := client.DialAddr("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	reply := new(ReflectionResponse)
+	if err := c.Call(ReflectionListMethod, &ReflectionRequest{}, reply); err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0)
+	for _, info := range reply.Services {
+		names = append(names, info.Name)
+	}
+	if len(names) != 4 || names[0] != "Forest" || names[1] != HealthService || names[2] != ReflectionService || names[3] != "Slow" {
+		t.Fatalf("unexpected services: %v", names)
+	}
+
+	reply = new(ReflectionResponse)
+	if err := c.Call(ReflectionListMethod, &ReflectionRequest{Service: "Forest"}, reply); err != nil {
+		t.Fatal(err)
+	}
+	if len(reply.Services) != 1 || len(reply.Services[0].Methods) != 1 {
+		t.Fatalf("expect only Forest.Grow, got %+v", reply.Services)
+	}
+	grow := reply.Services[0].Methods[0]
+	arg := grow.Arg
+	if grow.Name != "Grow" || arg.Name != "*server.Tree" || arg.Kind != "ptr" || arg.Elem.Kind != "struct" {
+		t.Fatalf("unexpected arg type: %+v", arg)
+	}
+	fields := arg.Elem.Fields
+	if len(fields) != 2 || fields[0].Name != "Value" || fields[0].Tag != `json:"value"` || fields[0].Type.Kind != "int" {
+		t.Fatalf("unexpected fields: %+v", fields)
+	}
+	if child := fields[1].Type.Elem.Elem; child.Name != "server.Tree" || !child.Ref {
+		t.Fatalf("expect recursive type to be a reference, got %+v", child)
+	}
+	if rly := grow.Reply.Elem; rly.Kind != "map" || rly.Key.Kind != "string" || rly.Elem.Name != "server.Tree" || rly.Elem.Ref {
+		t.Fatalf("unexpected reply type: %+v", rly)
+	}
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"net"
+	"simplerpc/client"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Adder struct {
+	A, B int
+}
+
+func TestRegisterNameAndFunc(t *testing.T) {
+	s := NewServer()
+	if err := s.RegisterName("ForestV2", new(Forest)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RegisterName("ForestV2", new(Forest)); err == nil {
+		t.Fatal("expect duplicated name to be refused")
+	}
+	if err := s.RegisterName("Forest.V3", new(Forest)); err == nil {
+		t.Fatal("expect name containing dot to be refused")
+	}
+	add := func(args Adder, rly *int) error {
+		*rly = args.A + args.B
+		return nil
+	}
+	if err := s.RegisterFunc("Math.Add", add); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RegisterFunc("Math.Add", add); err == nil {
+		t.Fatal("expect duplicated method to be refused")
+	}
+	//在已有服务上增加函数
+	if err := s.RegisterFunc("ForestV2.Count", func(args int, rly *int) error {
+		*rly = args
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, fn := range []interface{}{42, func(args int) error { return nil }, func(args int, rly int) error { return nil }} {
+		if err := s.RegisterFunc("Math.Bad", fn); err == nil {
+			t.Fatalf("expect %T to be refused", fn)
+		}
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Accept(lis)
+	defer s.Shutdown()
+	c, err := client.DialAddr("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var sum int
+	if err := c.Call("Math.Add", Adder{A: 1, B: 2}, &sum); err != nil || sum != 3 {
+		t.Fatalf("expect 3, got %d, %v", sum, err)
+	}
+	var count int
+	if err := c.Call("ForestV2.Count", 7, &count); err != nil || count != 7 {
+		t.Fatalf("expect 7, got %d, %v", count, err)
+	}
+	rly := make(map[string]Tree)
+	if err := c.Call("ForestV2.Grow", &Tree{}, &rly); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type Fast int
+
+func (f *Fast) Sleep(ms int, rly *int) error {
+	*rly = -ms
+	return nil
+}
+
+func TestUnregisterAndReplace(t *testing.T) {
+	s := NewServer()
+	_ = s.Registry(new(Slow))
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Accept(lis)
+	defer s.Shutdown()
+	c, err := client.DialAddr("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	done := make(chan int, 1)
+	go func() {
+		var rly int
+		_ = c.Call("Slow.Sleep", 300, &rly)
+		done <- rly
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if err := s.Replace("Slow", new(Fast)); err != nil {
+		t.Fatal(err)
+	}
+	var rly int
+	if err := c.Call("Slow.Sleep", 300, &rly); err != nil || rly != -300 {
+		t.Fatalf("expect new instance to handle the call, got %d, %v", rly, err)
+	}
+	if old := <-done; old != 300 {
+		t.Fatalf("expect in-flight call to finish on the old instance, got %d", old)
+	}
+
+	//RegisterFunc增加的方法在替换后保留
+	if err := s.RegisterFunc("Slow.Double", func(args int, rly *int) error {
+		*rly = args * 2
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Replace("Slow", new(Slow)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Call("Slow.Double", 21, &rly); err != nil || rly != 42 {
+		t.Fatalf("expect function method kept after replace, got %d, %v", rly, err)
+	}
+	if err := c.Call("Slow.Sleep", 1, &rly); err != nil || rly != 1 {
+		t.Fatalf("expect replaced instance to handle the call, got %d, %v", rly, err)
+	}
+
+	if err := s.Unregister("Slow"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Call("Slow.Sleep", 1, &rly); err == nil {
+		t.Fatal("expect call to unregistered service to fail")
+	}
+	if err := s.Unregister("Slow"); err == nil {
+		t.Fatal("expect unregistering a missing service to fail")
+	}
+	if err := s.Replace("Slow", new(Fast)); err == nil {
+		t.Fatal("expect replacing a missing service to fail")
+	}
+	if err := s.Unregister(HealthService); err == nil {
+		t.Fatal("expect builtin service to be kept")
+	}
+}
+
+type Typo int
+
+func (t *Typo) Good(args int, rly *int) error { return nil }
+
+func (t *Typo) NoError(args int, rly *int) {}
+
+func (t *Typo) Extra(args int, rly *int, more int) error { return nil }
+
+func (t *Typo) Hidden(args hiddenArgs, rly *int) error { return nil }
+
+func (t *Typo) ValueReply(args int, rly int) error { return nil }
+
+type hiddenArgs struct{}
+
+type lowercase int
+
+func (l lowercase) Echo(args int, rly *int) error { return nil }
+
+func TestRegisterDiagnostics(t *testing.T) {
+	s := NewServer()
+	if err := s.Registry(new(lowercase)); err == nil {
+		t.Fatal("expect unexported type name to be refused")
+	}
+	if err := s.RegisterName("Lower", new(lowercase)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Registry(new(Typo)); err != nil {
+		t.Fatal(err)
+	}
+	skipped := s.Skipped("Typo")
+	reasons := make(map[string]string)
+	for _, m := range skipped {
+		reasons[m.Name] = m.Reason
+	}
+	if len(skipped) != 4 || reasons["NoError"] == "" || reasons["Extra"] == "" || !strings.Contains(reasons["Hidden"], "not exported") ||
+		!strings.Contains(reasons["ValueReply"], "not a pointer") {
+		t.Fatalf("unexpected skipped methods: %+v", skipped)
+	}
+
+	s.SetStrict(true)
+	err := s.RegisterName("StrictTypo", new(Typo))
+	regErr, ok := err.(*RegisterError)
+	if !ok || regErr.Service != "StrictTypo" || len(regErr.Skipped) != 4 {
+		t.Fatalf("expect RegisterError, got %v", err)
+	}
+	if _, ok := s.services.Load("StrictTypo"); ok {
+		t.Fatal("expect failed service not to be registered")
+	}
+	//以值注册时指针接收者的方法不可用
+	if err := s.RegisterName("ValueForest", Forest(0)); err == nil || !strings.Contains(err.Error(), "pointer receiver") {
+		t.Fatalf("expect pointer receiver hint, got %v", err)
+	}
+	if err := s.Replace("Lower", new(Typo)); err == nil {
+		t.Fatal("expect strict mode to apply to Replace")
+	}
+	if err := s.RegisterName("StrictForest", new(Forest)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,32 +1,69 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"go/ast"
+	"io"
 	"log"
 	"net"
 	"reflect"
 	"simplerpc/codec"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+
+	"github.com/go-playground/validator/v10"
 )
 
 type Server struct {
 	services sync.Map
+	regMu    sync.Mutex //串行化服务的注册和修改
+	strict   bool
+
+	//优雅关闭需要跟踪的监听和连接
+	mu         sync.Mutex
+	cond       *sync.Cond
+	listeners  map[net.Listener]struct{}
+	conns      map[net.Conn]struct{}
+	onShutdown []func()
+	inShutdown bool
+
+	health       *Health
+	tagValidator atomic.Pointer[validator.Validate] //为nil时不做标签校验
 }
 
-func newServer() *Server {
-	return &Server{}
+func NewServer() *Server {
+	s := &Server{
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	s.health = newHealth(s)
+	for _, builtin := range []*Service{newService(HealthService, s.health), newService(ReflectionService, &Reflection{server: s})} {
+		s.services.Store(builtin.name, builtin)
+	}
+	return s
 }
 
-var defaultServer = newServer()
+var defaultServer = NewServer()
 
 func (s *Server) Accept(lis net.Listener) {
 
+	if !s.trackListener(lis, true) {
+		_ = lis.Close()
+		return
+	}
+	defer s.trackListener(lis, false)
 	for {
 		conn, err := lis.Accept()
 		if err != nil {
+			if s.shuttingDown() || errors.Is(err, net.ErrClosed) {
+				return
+			}
 			continue
 		}
 		go s.serverConn(conn)
@@ -42,18 +79,43 @@ func (s *Server) serverConn(conn net.Conn) {
 	//检验魔数，判断是否需要继续解析
 	//拿到编码方式，新建编解码对象，并通过它去执行下一步
 
+	if !s.trackConn(conn, true) {
+		_ = conn.Close()
+		return
+	}
+	defer s.trackConn(conn, false)
 	option := new(codec.Option)
-	if err := json.NewDecoder(conn).Decode(option); err != nil {
-		log.Fatalf("server error: parse option err: %v", err)
+	dec := json.NewDecoder(conn)
+	if err := dec.Decode(option); err != nil {
+		log.Printf("server error: parse option err: %v", err)
+		_ = conn.Close()
+		return
 	}
 	if option.MagicNumber != codec.MagicNum {
-		log.Fatalf("server error: invalid magic number:%v", option.MagicNumber)
+		log.Printf("server error: invalid magic number:%v", option.MagicNumber)
+		_ = conn.Close()
+		return
 	}
 	f, ok := codec.CodecFuncTable[option.CodecType]
 	if !ok {
-		log.Fatalf("server error: invailid codec type:%v", option.CodecType)
+		log.Printf("server error: invailid codec type:%v", option.CodecType)
+		_ = conn.Close()
+		return
 	}
-	s.serverCodec(f(conn))
+	//json解码器可能多读了紧跟在探头后的请求数据，需要先交给编解码器
+	//json.Encoder在探头末尾写了一个换行，只能去掉这一个字节，后面的数据可能恰好以空白字符开头
+	buffered, _ := io.ReadAll(dec.Buffered())
+	buffered = bytes.TrimPrefix(buffered, []byte("\n"))
+	s.serverCodec(f(&bufferedConn{Reader: io.MultiReader(bytes.NewReader(buffered), conn), Conn: conn}))
+}
+
+type bufferedConn struct {
+	io.Reader
+	net.Conn
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.Reader.Read(p)
 }
 
 type request struct {
@@ -76,6 +138,10 @@ func (s *Server) serverCodec(cc codec.Codec) {
 			if req == nil {
 				break
 			}
+			if req.h.OneWay {
+				log.Printf("rpc server: one-way call %s failed: %v", req.h.ServiceMethod, err)
+				continue
+			}
 			req.h.Err = err.Error()
 			s.sendResponse(cc, req.h, invalidRequest, sending, wg)
 			continue
@@ -94,12 +160,17 @@ func (s *Server) readRequest(cc codec.Codec) (*request, error) {
 	//先解析请求头
 	var h = new(codec.Header)
 	if err := cc.ReadHeader(h); err != nil {
-		log.Fatalf("rpc server: read header err:%v", err)
+		//连接被对端关闭或者数据流已损坏，交由serverCodec结束该连接
+		return nil, err
 	}
 	//解析请求体
 	req := &request{h: h}
 	service, mType, err := s.findService(h.ServiceMethod)
 	if err != nil {
+		//丢弃请求体，连接上的下一个请求才能被正确解析
+		if bodyErr := cc.ReadBody(nil); bodyErr != nil {
+			return nil, bodyErr
+		}
 		return req, err
 	}
 	req.sviv = service
@@ -112,7 +183,11 @@ func (s *Server) readRequest(cc codec.Codec) (*request, error) {
 		argvi = req.argv.Addr().Interface()
 	}
 	if err := cc.ReadBody(argvi); err != nil {
-		log.Fatalf("rpc server: read body err:%v", err)
+		log.Printf("rpc server: read body err:%v", err)
+		return nil, err
+	}
+	if err := s.validate(argvi); err != nil {
+		return req, err
 	}
 
 	return req, nil
@@ -135,29 +210,205 @@ func (s *Server) handleRequest(cc codec.Codec, req *request, sending *sync.Mutex
 
 	defer wg.Done()
 	err := req.sviv.call(req.mType, req.argv, req.rlyv)
+	//单向调用的客户端不等待响应
+	if req.h.OneWay {
+		if err != nil {
+			log.Printf("rpc server: one-way call %s failed: %v", req.h.ServiceMethod, err)
+		}
+		return
+	}
 	if err != nil {
-		log.Fatalf("rpc server:mehode:%s fail calling", req.mType.method.Name)
+		//方法返回的错误交给客户端处理，不影响连接上的其他请求
+		req.h.Err = err.Error()
+		s.sendResponse(cc, req.h, invalidRequest, sending, wg)
+		return
 	}
 	s.sendResponse(cc, req.h, req.rlyv.Interface(), sending, wg)
 }
 
 func (s *Server) Registry(src interface{}) error {
-	name := reflect.Indirect(reflect.ValueOf(src)).Type().Name()
-	_, exist := s.services.Load(name)
-	if exist {
+	if src == nil {
+		return errors.New("rpc server:service is nil")
+	}
+	name := typeName(src)
+	if !ast.IsExported(name) {
+		return errors.New(fmt.Sprintf("rpc server:%q is not a valid service name, use RegisterName instead", name))
+	}
+	return s.RegisterName(name, src)
+}
+func Registry(src interface{}) error {
+	return defaultServer.Registry(src)
+}
+
+// RegisterName 以name为服务名注册src，同一个类型可以以不同的名字（如不同版本）注册多次
+func (s *Server) RegisterName(name string, src interface{}) error {
+	if err := checkServiceName(name); err != nil {
+		return err
+	}
+	s.regMu.Lock()
+	defer s.regMu.Unlock()
+	if _, exist := s.services.Load(name); exist {
 		return errors.New(fmt.Sprintf("rpc server:service:%s has existed", name))
 	}
+	service, err := s.buildService(name, src)
+	if err != nil {
+		return err
+	}
+	s.services.Store(name, service)
+	return nil
+}
+func RegisterName(name string, src interface{}) error {
+	return defaultServer.RegisterName(name, src)
+}
+
+// RegisterFunc 将函数fn注册为serviceMethod，fn的形式为func(args T, reply *R) error
+// 服务不存在时新建，已存在时在其上增加该方法，方法名不能与已有的方法重复
+func (s *Server) RegisterFunc(serviceMethod string, fn interface{}) error {
+	split := strings.Split(serviceMethod, ".")
+	if len(split) != 2 || !ast.IsExported(split[1]) {
+		return errors.New(fmt.Sprintf("rpc server:invalid service method:%s", serviceMethod))
+	}
+	if err := checkServiceName(split[0]); err != nil {
+		return err
+	}
+	mType, err := funcType(fn)
+	if err != nil {
+		return err
+	}
+	s.regMu.Lock()
+	defer s.regMu.Unlock()
+	var service *Service
+	if svi, ok := s.services.Load(split[0]); ok {
+		service = svi.(*Service)
+		if _, exist := service.methods[split[1]]; exist {
+			return errors.New(fmt.Sprintf("rpc server:method:%s has existed", serviceMethod))
+		}
+	}
+	s.services.Store(split[0], service.withFunc(split[0], split[1], mType))
+	log.Printf("rpc server: %s was registed successfully", serviceMethod)
+	return nil
+}
+func RegisterFunc(serviceMethod string, fn interface{}) error {
+	return defaultServer.RegisterFunc(serviceMethod, fn)
+}
 
-	service := newService(src)
-	s.services.Store(service.name, service)
+// Unregister 移除服务，之后的请求返回服务不存在；已经读取到服务的请求仍在原实例上执行完
+func (s *Server) Unregister(name string) error {
+	s.regMu.Lock()
+	defer s.regMu.Unlock()
+	if err := s.checkReplaceable(name); err != nil {
+		return err
+	}
+	s.services.Delete(name)
+	s.health.forget(name)
+	log.Printf("rpc server: %s was unregisted", name)
 	return nil
 }
-func Registry(src interface{}) error {
-	return defaultServer.Registry(src)
+func Unregister(name string) error {
+	return defaultServer.Unregister(name)
+}
+
+// Replace 用src替换已注册的服务name，之后的请求由src处理，进行中的请求仍在原实例上执行完
+// 通过RegisterFunc增加的方法会保留到新服务上，src中有同名方法时以src为准
+func (s *Server) Replace(name string, src interface{}) error {
+	s.regMu.Lock()
+	defer s.regMu.Unlock()
+	if err := s.checkReplaceable(name); err != nil {
+		return err
+	}
+	service, err := s.buildService(name, src)
+	if err != nil {
+		return err
+	}
+	old, _ := s.services.Load(name)
+	for method, m := range old.(*Service).methods {
+		if _, ok := service.methods[method]; !ok && m.fn.IsValid() {
+			service.methods[method] = m
+		}
+	}
+	s.services.Store(name, service)
+	log.Printf("rpc server: %s was replaced", name)
+	return nil
+}
+func Replace(name string, src interface{}) error {
+	return defaultServer.Replace(name, src)
+}
+
+// checkReplaceable 在持有s.regMu时调用，内置服务不允许移除或替换
+func (s *Server) checkReplaceable(name string) error {
+	if name == HealthService || name == ReflectionService {
+		return errors.New(fmt.Sprintf("rpc server:builtin service:%s can not be changed", name))
+	}
+	if _, exist := s.services.Load(name); !exist {
+		return errors.New(fmt.Sprintf("rpc server:service:%s not found", name))
+	}
+	return nil
+}
+
+// SetStrict 严格模式下，服务存在签名不符合要求的导出方法或没有可用方法时注册失败，
+// 返回的*RegisterError列出了每个方法不可用的原因；非严格模式下这些方法被跳过，可以通过Skipped查看
+func (s *Server) SetStrict(strict bool) {
+	s.regMu.Lock()
+	defer s.regMu.Unlock()
+	s.strict = strict
+}
+func SetStrict(strict bool) {
+	defaultServer.SetStrict(strict)
+}
+
+// Skipped 返回服务注册时被跳过的方法及原因
+func (s *Server) Skipped(name string) []SkippedMethod {
+	svi, ok := s.services.Load(name)
+	if !ok {
+		return nil
+	}
+	return svi.(*Service).skipped
+}
+func Skipped(name string) []SkippedMethod {
+	return defaultServer.Skipped(name)
+}
+
+// buildService 在持有s.regMu时调用
+func (s *Server) buildService(name string, src interface{}) (*Service, error) {
+	if src == nil {
+		return nil, errors.New(fmt.Sprintf("rpc server:service:%s is nil", name))
+	}
+	service := newService(name, src)
+	if s.strict && (len(service.skipped) > 0 || len(service.methods) == 0) {
+		return nil, &RegisterError{Service: name, Skipped: service.skipped}
+	}
+	return service, nil
+}
+
+func checkServiceName(name string) error {
+	if name == "" || strings.Contains(name, ".") {
+		return errors.New(fmt.Sprintf("rpc server:invalid service name:%q", name))
+	}
+	return nil
+}
+
+// Services 返回服务器上已注册的所有业务服务名，用于向注册中心登记，不包括内置的Health和Reflection
+func (s *Server) Services() []string {
+	names := make([]string, 0)
+	s.services.Range(func(key, _ interface{}) bool {
+		if name := key.(string); name != HealthService && name != ReflectionService {
+			names = append(names, name)
+		}
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
+func Services() []string {
+	return defaultServer.Services()
 }
 
 func (s *Server) findService(serviceName string) (service *Service, mType *MethodType, err error) {
 	split := strings.Split(serviceName, ".")
+	if len(split) != 2 {
+		err = errors.New(fmt.Sprintf("rpc server:invalid service method:%s", serviceName))
+		return
+	}
 
 	svi, ok := s.services.Load(split[0])
 	if !ok {
@@ -1,13 +1,16 @@
 package server
 
 import (
+	"fmt"
 	"go/ast"
 	"log"
 	"reflect"
+	"strings"
 )
 
 type MethodType struct {
 	method           reflect.Method //call的时候需要
+	fn               reflect.Value  //RegisterFunc注册的函数，有效时代替method
 	argType, rlyType reflect.Type   //知道参数类型才能赋值，并传入执行call
 }
 
@@ -48,50 +51,141 @@ type Service struct {
 	svit    reflect.Type           // 注册该service的method需要通过它查看所有方法并注册
 	sviv    reflect.Value          //call的时候需要
 	methods map[string]*MethodType //注册的结果，key为methodName，value为该method的methodType
+	skipped []SkippedMethod        //没有注册的导出方法及原因
 }
 
-func newService(src interface{}) *Service {
+// SkippedMethod 注册时因签名不符合要求而没有注册的导出方法
+type SkippedMethod struct {
+	Name   string
+	Reason string
+}
+
+// RegisterError 严格模式下服务存在不可用的导出方法时返回
+type RegisterError struct {
+	Service string
+	Skipped []SkippedMethod
+}
+
+func (e *RegisterError) Error() string {
+	if len(e.Skipped) == 0 {
+		return fmt.Sprintf("rpc server:service:%s has no usable method", e.Service)
+	}
+	reasons := make([]string, 0, len(e.Skipped))
+	for _, m := range e.Skipped {
+		reasons = append(reasons, m.Name+": "+m.Reason)
+	}
+	return fmt.Sprintf("rpc server:service:%s has unusable methods: %s", e.Service, strings.Join(reasons, "; "))
+}
+
+// newService 以name为服务名注册src上的方法
+func newService(name string, src interface{}) *Service {
 	s := new(Service)
 	s.svit = reflect.TypeOf(src)
 	s.sviv = reflect.ValueOf(src)
-	//指针类型取不到正确的名字
-	s.name = reflect.Indirect(s.sviv).Type().Name()
-	if !ast.IsExported(s.name) {
-		log.Fatalf("rpc server: %s is not A valid service name", s.name)
-	}
+	s.name = name
 	s.registerMethods()
 	return s
 }
 
+// typeName 服务默认以接收者的类型名为名字，指针类型取不到正确的名字
+func typeName(src interface{}) string {
+	return reflect.Indirect(reflect.ValueOf(src)).Type().Name()
+}
+
+// withFunc 返回在s的基础上增加了函数fn的新服务，s为nil时新建只包含函数的服务
+// 服务注册后会被并发读取，因此不修改s本身
+func (s *Service) withFunc(name, method string, mType *MethodType) *Service {
+	ns := &Service{name: name, methods: make(map[string]*MethodType)}
+	if s != nil {
+		ns.svit, ns.sviv, ns.skipped = s.svit, s.sviv, s.skipped
+		for key, m := range s.methods {
+			ns.methods[key] = m
+		}
+	}
+	ns.methods[method] = mType
+	return ns
+}
+
+// funcType 检查fn是否为func(args T, reply *R) error形式的函数
+func funcType(fn interface{}) (*MethodType, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return nil, fmt.Errorf("rpc server: %T is not a function", fn)
+	}
+	t := v.Type()
+	if t.NumIn() != 2 || t.NumOut() != 1 || t.Out(0) != reflect.TypeOf((*error)(nil)).Elem() {
+		return nil, fmt.Errorf("rpc server: function %s must be func(args T, reply *R) error", t)
+	}
+	argType, rlyType := t.In(0), t.In(1)
+	if rlyType.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("rpc server: reply type %s of function %s must be a pointer", rlyType, t)
+	}
+	if !isExportedOrBuiltinType(argType) || !isExportedOrBuiltinType(rlyType) {
+		return nil, fmt.Errorf("rpc server: argument or reply type of function %s is not exported", t)
+	}
+	return &MethodType{fn: v, argType: argType, rlyType: rlyType}, nil
+}
+
 func (s *Service) registerMethods() {
 
 	s.methods = make(map[string]*MethodType)
 	for i := 0; i < s.svit.NumMethod(); i++ {
 
 		method := s.svit.Method(i)
-		if method.Type.NumIn() != 3 || method.Type.NumOut() != 1 {
-			continue
-		}
-		if method.Type.Out(0) != reflect.TypeOf((*error)(nil)).Elem() {
-			continue
-		}
-		argType, rlyType := method.Type.In(1), method.Type.In(2)
-		if !isExportedOrBuiltinType(argType) || !isExportedOrBuiltinType(rlyType) {
+		if reason := checkMethod(method.Type); reason != "" {
+			s.skipped = append(s.skipped, SkippedMethod{Name: method.Name, Reason: reason})
+			log.Printf("rpc server: %s.%s was skipped: %s", s.name, method.Name, reason)
 			continue
 		}
 		s.methods[method.Name] = &MethodType{
 			method:  method,
-			argType: argType,
-			rlyType: rlyType,
+			argType: method.Type.In(1),
+			rlyType: method.Type.In(2),
 		}
 		log.Printf("rpc server: %s.%s was registed successfully", s.name, method.Name)
 	}
+	//以值注册时，指针接收者的方法不在方法集中，很容易误用
+	if s.svit.Kind() != reflect.Ptr {
+		ptr := reflect.PtrTo(s.svit)
+		for i := 0; i < ptr.NumMethod(); i++ {
+			method := ptr.Method(i)
+			if _, ok := s.svit.MethodByName(method.Name); !ok {
+				s.skipped = append(s.skipped, SkippedMethod{Name: method.Name, Reason: "method has pointer receiver, register a pointer instead"})
+				log.Printf("rpc server: %s.%s was skipped: method has pointer receiver", s.name, method.Name)
+			}
+		}
+	}
 
 }
 
+// checkMethod 返回方法不能注册的原因，可以注册时返回空串，mt的第一个参数为接收者
+func checkMethod(mt reflect.Type) string {
+	if mt.NumIn() != 3 || mt.NumOut() != 1 {
+		return fmt.Sprintf("want func(args T, reply *R) error, has %d arguments and %d results", mt.NumIn()-1, mt.NumOut())
+	}
+	if mt.Out(0) != reflect.TypeOf((*error)(nil)).Elem() {
+		return fmt.Sprintf("result type %s is not error", mt.Out(0))
+	}
+	if argType := mt.In(1); !isExportedOrBuiltinType(argType) {
+		return fmt.Sprintf("argument type %s is not exported", argType)
+	}
+	rlyType := mt.In(2)
+	if rlyType.Kind() != reflect.Ptr {
+		return fmt.Sprintf("reply type %s is not a pointer", rlyType)
+	}
+	if !isExportedOrBuiltinType(rlyType) {
+		return fmt.Sprintf("reply type %s is not exported", rlyType)
+	}
+	return ""
+}
+
 func (s *Service) call(m *MethodType, argv, rlyv reflect.Value) error {
-	f := m.method.Func
-	callRes := f.Call([]reflect.Value{s.sviv, argv, rlyv})
+	var callRes []reflect.Value
+	if m.fn.IsValid() {
+		callRes = m.fn.Call([]reflect.Value{argv, rlyv})
+	} else {
+		callRes = m.method.Func.Call([]reflect.Value{s.sviv, argv, rlyv})
+	}
 	if err := callRes[0].Interface(); err != nil {
 		return err.(error)
 	}
@@ -0,0 +1,96 @@
+package server
+
+import "net"
+
+// RegisterOnShutdown 注册在Shutdown开始时执行的函数，如向注册中心注销本实例
+func (s *Server) RegisterOnShutdown(f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onShutdown = append(s.onShutdown, f)
+}
+
+func RegisterOnShutdown(f func()) {
+	defaultServer.RegisterOnShutdown(f)
+}
+
+// Shutdown 优雅关闭服务器
+// 先将健康状态置为NOT_SERVING并执行RegisterOnShutdown注册的函数，让客户端不再路由到本实例，
+// 再关闭所有监听并停止读取新的请求，等在途调用的结果写回后关闭连接
+func (s *Server) Shutdown() {
+	s.mu.Lock()
+	if s.inShutdown {
+		s.mu.Unlock()
+		return
+	}
+	s.inShutdown = true
+	hooks := s.onShutdown
+	s.mu.Unlock()
+
+	s.health.shutdown()
+
+	for _, f := range hooks {
+		f()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for lis := range s.listeners {
+		_ = lis.Close()
+	}
+	for conn := range s.conns {
+		closeRead(conn)
+	}
+	for len(s.conns) > 0 {
+		s.cond.Wait()
+	}
+}
+
+func Shutdown() {
+	defaultServer.Shutdown()
+}
+
+// closeRead 只关闭连接的读端，serverCodec读到EOF后会等待在途调用结束再关闭连接
+func closeRead(conn net.Conn) {
+	if c, ok := conn.(interface{ CloseRead() error }); ok {
+		_ = c.CloseRead()
+		return
+	}
+	_ = conn.Close()
+}
+
+func (s *Server) shuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inShutdown
+}
+
+// trackListener 登记或移除监听，关闭过程中不再接受新的监听
+func (s *Server) trackListener(lis net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !add {
+		delete(s.listeners, lis)
+		return true
+	}
+	if s.inShutdown {
+		return false
+	}
+	s.listeners[lis] = struct{}{}
+	return true
+}
+
+// trackConn 登记或移除连接，关闭过程中不再接受新的连接
+func (s *Server) trackConn(conn net.Conn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !add {
+		delete(s.conns, conn)
+		s.cond.Broadcast()
+		return true
+	}
+	if s.inShutdown {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
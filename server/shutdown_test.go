@@ -0,0 +1,54 @@
+package server
+
+import (
+	"net"
+	"simplerpc/client"
+	"testing"
+	"time"
+)
+
+type Slow int
+
+func (s *Slow) Sleep(ms int, rly *int) error {
+	time.Sleep(time.Duration(ms) * time.Millisecond)
+	*rly = ms
+	return nil
+}
+
+func TestShutdown(t *testing.T) {
+	s := NewServer()
+	if err := s.Registry(new(Slow)); err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Accept(lis)
+
+	c, err := client.DialAddr("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		var rly int
+		done <- c.Call("Slow.Sleep", 200, &rly)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	left := false
+	s.RegisterOnShutdown(func() { left = true })
+	s.Shutdown()
+
+	//在途调用在关闭前完成
+	if err := <-done; err != nil {
+		t.Fatalf("in-flight call failed: %v", err)
+	}
+	if !left {
+		t.Fatal("expect shutdown hook called")
+	}
+	if _, err := client.DialAddr("tcp", lis.Addr().String()); err == nil {
+		t.Fatal("expect dial to fail after shutdown")
+	}
+}
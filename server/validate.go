@@ -0,0 +1,63 @@
+package server
+
+import (
+	"reflect"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// 参数校验，在解析请求体之后、调用方法之前执行，校验失败时直接返回错误，不再调用方法：
+// 参数类型实现了Validator时调用其Validate方法；开启EnableTagValidation后，
+// 结构体参数还会按validate标签校验，如 `validate:"required,min=1"`
+
+// InvalidArgumentPrefix 参数校验失败时返回给客户端的错误前缀
+const InvalidArgumentPrefix = "rpc server: invalid argument: "
+
+// Validator 需要自行校验的参数类型实现该接口，值接收者和指针接收者均可
+type Validator interface {
+	Validate() error
+}
+
+type invalidArgument struct {
+	err error
+}
+
+func (e *invalidArgument) Error() string {
+	return InvalidArgumentPrefix + e.err.Error()
+}
+
+func (e *invalidArgument) Unwrap() error {
+	return e.err
+}
+
+// EnableTagValidation 开启或关闭基于validate标签的结构体校验
+func (s *Server) EnableTagValidation(enable bool) {
+	if !enable {
+		s.tagValidator.Store(nil)
+		return
+	}
+	s.tagValidator.Store(validator.New())
+}
+func EnableTagValidation(enable bool) {
+	defaultServer.EnableTagValidation(enable)
+}
+
+// validate argvi为参数的指针，参数本身是指针类型时为参数本身
+func (s *Server) validate(argvi interface{}) error {
+	if v := reflect.ValueOf(argvi); v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil
+	}
+	if v, ok := argvi.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return &invalidArgument{err: err}
+		}
+	}
+	tags := s.tagValidator.Load()
+	if tags == nil || reflect.Indirect(reflect.ValueOf(argvi)).Kind() != reflect.Struct {
+		return nil
+	}
+	if err := tags.Struct(argvi); err != nil {
+		return &invalidArgument{err: err}
+	}
+	return nil
+}
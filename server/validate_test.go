@@ -0,0 +1,72 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"simplerpc/client"
+	"strings"
+	"testing"
+)
+
+type Order struct {
+	Item  string `validate:"required"`
+	Count int    `validate:"min=1,max=10"`
+}
+
+func (o Order) Validate() error {
+	if o.Item == "forbidden" {
+		return errors.New("item is forbidden")
+	}
+	return nil
+}
+
+type Shop struct {
+	calls int
+}
+
+func (s *Shop) Buy(order *Order, rly *int) error {
+	s.calls++
+	*rly = order.Count
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	s := NewServer()
+	shop := new(Shop)
+	_ = s.Registry(shop)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Accept(lis)
+	defer s.Shutdown()
+	c, err := client.DialAddr("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var rly int
+	err = c.Call("Shop.Buy", &Order{Item: "forbidden", Count: 1}, &rly)
+	if err == nil || !strings.HasPrefix(err.Error(), InvalidArgumentPrefix) || !strings.Contains(err.Error(), "forbidden") {
+		t.Fatalf("expect invalid argument error, got %v", err)
+	}
+	//未开启标签校验时不检查validate标签
+	if err := c.Call("Shop.Buy", &Order{Item: "apple", Count: 100}, &rly); err != nil || rly != 100 {
+		t.Fatalf("expect call to succeed, got %d, %v", rly, err)
+	}
+
+	s.EnableTagValidation(true)
+	for _, order := range []*Order{{Count: 1}, {Item: "apple", Count: 100}} {
+		err = c.Call("Shop.Buy", order, &rly)
+		if err == nil || !strings.HasPrefix(err.Error(), InvalidArgumentPrefix) {
+			t.Fatalf("expect invalid argument error for %+v, got %v", order, err)
+		}
+	}
+	if err := c.Call("Shop.Buy", &Order{Item: "apple", Count: 3}, &rly); err != nil || rly != 3 {
+		t.Fatalf("expect 3, got %d, %v", rly, err)
+	}
+	if shop.calls != 2 {
+		t.Fatalf("expect invalid requests not to reach the method, got %d calls", shop.calls)
+	}
+}